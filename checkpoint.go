@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const checkpointHeader = "# filist checkpoint"
+
+// checkpoint 出力済みのエントリ数と最後のパスを記録する
+// 走査順は常に同じ(ディレクトリ内は名前順)なので、再開時は記録した数だけエントリを読み飛ばす
+type checkpoint struct {
+	f *os.File
+	// 出力の内容を左右するオプション (再開時は前回と同じでなければならない)
+	options string
+	// 出力済みのエントリ数と最後のパス
+	count int64
+	path  string
+	// 再開時に読み飛ばすエントリ数と、その最後のパス
+	resumeCount int64
+	resumePath  string
+	skipped     int64
+}
+
+// openCheckpoint resume の場合は記録済みの位置から再開し、そうでなければ最初から記録する
+// 再開時に記録が無い場合は、最初から記録する
+// options は outputOptions の値で、記録時と異なる場合は列の構成などが違う出力を追記してしまうため再開しない
+func openCheckpoint(checkpointFile string, options string, resume bool) (*checkpoint, error) {
+
+	c := &checkpoint{options: options}
+
+	if resume {
+		count, path, recorded, err := readCheckpoint(checkpointFile)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil && recorded != options {
+			return nil, fmt.Errorf("checkpoint was recorded with different arguments or output options: %s", checkpointFile)
+		}
+		c.count = count
+		c.path = path
+		c.resumeCount = count
+		c.resumePath = path
+	}
+
+	f, err := os.OpenFile(checkpointFile, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	c.f = f
+
+	if err := c.write(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// outputOptions 出力の内容を左右するオプション (対象のパス、列、区切り)
+// --from-file のパスは多くなることがあるため、まとめてハッシュ値にする
+func outputOptions(dirs []string, paths []string, columnNames []string, nullTerminated bool, escape bool) string {
+
+	h := sha256.New()
+	for _, dir := range dirs {
+		fmt.Fprintf(h, "dir=%s\n", strconv.Quote(dir))
+	}
+	for _, path := range paths {
+		fmt.Fprintf(h, "path=%s\n", strconv.Quote(path))
+	}
+	for _, name := range columnNames {
+		fmt.Fprintf(h, "column=%s\n", strconv.Quote(name))
+	}
+	fmt.Fprintf(h, "null=%t\nescape=%t\n", nullTerminated, escape)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func readCheckpoint(checkpointFile string) (int64, string, string, error) {
+
+	f, err := os.Open(checkpointFile)
+	if err != nil {
+		return 0, "", "", err
+	}
+	defer f.Close()
+
+	var count int64
+	var path string
+	var options string
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() || scanner.Text() != checkpointHeader {
+		return 0, "", "", fmt.Errorf("not a checkpoint file: %s", checkpointFile)
+	}
+
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), "=")
+
+		switch key {
+		case "options":
+			options = value
+		case "count":
+			count, err = strconv.ParseInt(value, 10, 64)
+		case "path":
+			path, err = strconv.Unquote(value)
+		}
+		if err != nil {
+			return 0, "", "", fmt.Errorf("invalid checkpoint file: %s: %w", checkpointFile, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, "", "", err
+	}
+
+	return count, path, options, nil
+}
+
+// skip 前回までに出力済みのエントリか
+func (c *checkpoint) skip(path string) (bool, error) {
+
+	if c.skipped >= c.resumeCount {
+		return false, nil
+	}
+
+	c.skipped++
+
+	if c.skipped == c.resumeCount && path != c.resumePath {
+		// 前回から対象が変わっていると、どこまで出力済みか分からない
+		return false, fmt.Errorf("checkpoint does not match at entry %d: %s was recorded, but found %s", c.skipped, c.resumePath, path)
+	}
+
+	return true, nil
+}
+
+// record エントリを出力したことを記録する
+func (c *checkpoint) record(path string) error {
+
+	c.count++
+	c.path = path
+
+	return c.write()
+}
+
+// finish 走査が終わった時点で、記録済みの位置まで到達しているかを確認する
+func (c *checkpoint) finish() error {
+
+	if c.skipped < c.resumeCount {
+		return fmt.Errorf("checkpoint does not match: %d entries were recorded, but only %d found", c.resumeCount, c.skipped)
+	}
+
+	return nil
+}
+
+func (c *checkpoint) write() error {
+
+	data := fmt.Sprintf("%s\noptions=%s\ncount=%d\npath=%s\n", checkpointHeader, c.options, c.count, strconv.Quote(c.path))
+
+	// 中断されても記録が失われないように、エントリごとに上書きする
+	if _, err := c.f.WriteAt([]byte(data), 0); err != nil {
+		return err
+	}
+
+	return c.f.Truncate(int64(len(data)))
+}
+
+func (c *checkpoint) close() error {
+	return c.f.Close()
+}
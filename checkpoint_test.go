@@ -0,0 +1,184 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint(t *testing.T) {
+
+	// ARRANGE
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint")
+
+	c, err := openCheckpoint(checkpointFile, "abc", false)
+	require.NoError(t, err)
+
+	// ACT
+	require.NoError(t, c.record("/data/a.txt"))
+	require.NoError(t, c.record("/data/b.txt"))
+	require.NoError(t, c.close())
+
+	// ASSERT
+	data, err := os.ReadFile(checkpointFile)
+	require.NoError(t, err)
+	assert.Equal(t, "# filist checkpoint\noptions=abc\ncount=2\npath=\"/data/b.txt\"\n", string(data))
+
+	count, path, options, err := readCheckpoint(checkpointFile)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", options)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, "/data/b.txt", path)
+}
+
+func TestCheckpoint_Resume(t *testing.T) {
+
+	// ARRANGE
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint")
+	require.NoError(t, os.WriteFile(checkpointFile, []byte("# filist checkpoint\noptions=abc\ncount=2\npath=\"/data/b.txt\"\n"), 0644))
+
+	c, err := openCheckpoint(checkpointFile, "abc", true)
+	require.NoError(t, err)
+	defer c.close()
+
+	// ACT & ASSERT
+	for _, path := range []string{"/data/a.txt", "/data/b.txt"} {
+		skip, err := c.skip(path)
+		require.NoError(t, err)
+		assert.True(t, skip)
+	}
+
+	skip, err := c.skip("/data/c.txt")
+	require.NoError(t, err)
+	assert.False(t, skip)
+
+	require.NoError(t, c.record("/data/c.txt"))
+	require.NoError(t, c.finish())
+
+	count, path, _, err := readCheckpoint(checkpointFile)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, "/data/c.txt", path)
+}
+
+func TestCheckpoint_Resume_NotExist(t *testing.T) {
+
+	// ARRANGE
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint")
+
+	// ACT
+	c, err := openCheckpoint(checkpointFile, "abc", true)
+
+	// ASSERT
+	require.NoError(t, err)
+	defer c.close()
+
+	skip, err := c.skip("/data/a.txt")
+	require.NoError(t, err)
+	assert.False(t, skip)
+}
+
+func TestCheckpoint_Resume_Mismatch(t *testing.T) {
+
+	// ARRANGE
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint")
+	require.NoError(t, os.WriteFile(checkpointFile, []byte("# filist checkpoint\noptions=abc\ncount=2\npath=\"/data/b.txt\"\n"), 0644))
+
+	c, err := openCheckpoint(checkpointFile, "abc", true)
+	require.NoError(t, err)
+	defer c.close()
+
+	_, err = c.skip("/data/a.txt")
+	require.NoError(t, err)
+
+	// ACT
+	_, err = c.skip("/data/a2.txt")
+
+	// ASSERT
+	assert.EqualError(t, err, "checkpoint does not match at entry 2: /data/b.txt was recorded, but found /data/a2.txt")
+}
+
+func TestCheckpoint_Resume_OptionsMismatch(t *testing.T) {
+
+	// ARRANGE
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint")
+	data := "# filist checkpoint\noptions=abc\ncount=2\npath=\"/data/b.txt\"\n"
+	require.NoError(t, os.WriteFile(checkpointFile, []byte(data), 0644))
+
+	// ACT
+	_, err := openCheckpoint(checkpointFile, "xyz", true)
+
+	// ASSERT
+	assert.EqualError(t, err, "checkpoint was recorded with different arguments or output options: "+checkpointFile)
+
+	// 記録は変更しない
+	after, err := os.ReadFile(checkpointFile)
+	require.NoError(t, err)
+	assert.Equal(t, data, string(after))
+}
+
+func TestOutputOptions(t *testing.T) {
+
+	// ARRANGE
+	base := outputOptions([]string{"d"}, nil, []string{"rel"}, false, false)
+
+	// ACT & ASSERT
+	assert.Equal(t, base, outputOptions([]string{"d"}, nil, []string{"rel"}, false, false))
+	assert.NotEqual(t, base, outputOptions([]string{"e"}, nil, []string{"rel"}, false, false))
+	assert.NotEqual(t, base, outputOptions([]string{"d"}, []string{"a.txt"}, []string{"rel"}, false, false))
+	assert.NotEqual(t, base, outputOptions([]string{"d"}, nil, []string{"rel", "md5"}, false, false))
+	assert.NotEqual(t, base, outputOptions([]string{"d"}, nil, []string{"rel"}, true, false))
+	assert.NotEqual(t, base, outputOptions([]string{"d"}, nil, []string{"rel"}, false, true))
+}
+
+func TestCheckpoint_Resume_NotReached(t *testing.T) {
+
+	// ARRANGE
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint")
+	require.NoError(t, os.WriteFile(checkpointFile, []byte("# filist checkpoint\noptions=abc\ncount=2\npath=\"/data/b.txt\"\n"), 0644))
+
+	c, err := openCheckpoint(checkpointFile, "abc", true)
+	require.NoError(t, err)
+	defer c.close()
+
+	_, err = c.skip("/data/a.txt")
+	require.NoError(t, err)
+
+	// ACT
+	err = c.finish()
+
+	// ASSERT
+	assert.EqualError(t, err, "checkpoint does not match: 2 entries were recorded, but only 1 found")
+}
+
+func TestReadCheckpoint_Invalid(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{"header", "count=1\n", "not a checkpoint file: "},
+		{"count", "# filist checkpoint\ncount=x\n", "invalid checkpoint file: "},
+		{"path", "# filist checkpoint\npath=a.txt\n", "invalid checkpoint file: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ARRANGE
+			checkpointFile := filepath.Join(t.TempDir(), "checkpoint")
+			require.NoError(t, os.WriteFile(checkpointFile, []byte(tt.data), 0644))
+
+			// ACT
+			_, _, _, err := readCheckpoint(checkpointFile)
+
+			// ASSERT
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected+checkpointFile)
+		})
+	}
+}
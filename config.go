@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/onozaty/filist/filist"
+	flag "github.com/spf13/pflag"
+)
+
+// 設定ファイル
+// 1行に1つ "オプションの長い名前 = 値" を書く (値を省略した場合は、値無しでオプションを指定したものとみなす)
+// "[名前]" 以降はプロファイルの設定で、--profile で名前を指定した場合のみ使う
+const projectConfigFileName = ".filist"
+
+// プロジェクトの設定ファイルでは指定できないオプション
+// プロジェクトの設定ファイルはカレントディレクトリやその親から探すため、他人が置いたファイルかもしれない
+// コマンドの実行や、任意のファイルへの書き込み、鍵の読み込みにつながるものは、ユーザの設定ファイルかコマンドラインでのみ指定できる
+var userOnlyFlags = map[string]bool{
+	"exec-column":  true,
+	"exec-timeout": true,
+	"exec-jobs":    true,
+	"checkpoint":   true,
+	"resume":       true,
+	"key-file":     true,
+	"sign":         true,
+}
+
+// 列を選ぶオプション (列の名前と同じオプション以外)
+// コマンドラインでどれかが指定されていれば、設定ファイルの列の指定は使わない
+var columnFlags = map[string]bool{
+	"columns":     true,
+	"hash":        true,
+	"hmac":        true,
+	"exec-column": true,
+}
+
+// 同時に指定できないオプション (片方がコマンドラインで指定されていれば、設定ファイルのもう片方は使わない)
+var exclusiveFlags = map[string]string{
+	"human":       "si",
+	"si":          "human",
+	"no-hidden":   "hidden-only",
+	"hidden-only": "no-hidden",
+}
+
+type configFile struct {
+	path string
+	// プロジェクトの設定ファイルか
+	project  bool
+	defaults []configValue
+	profiles map[string][]configValue
+}
+
+type configValue struct {
+	key      string
+	value    string
+	hasValue bool
+	line     int
+}
+
+// configValues オプションごとの設定値 (最初に現れた順を保持する)
+type configValues struct {
+	keys   []string
+	values map[string][]configValue
+	paths  map[string]string
+}
+
+func newConfigValues() *configValues {
+	return &configValues{
+		values: map[string][]configValue{},
+		paths:  map[string]string{},
+	}
+}
+
+// merge 同じオプションの値は置き換える
+func (c *configValues) merge(path string, values []configValue) {
+
+	grouped := map[string][]configValue{}
+	var keys []string
+	for _, value := range values {
+		if _, ok := grouped[value.key]; !ok {
+			keys = append(keys, value.key)
+		}
+		grouped[value.key] = append(grouped[value.key], value)
+	}
+
+	for _, key := range keys {
+		if _, ok := c.values[key]; !ok {
+			c.keys = append(c.keys, key)
+		}
+		c.values[key] = grouped[key]
+		c.paths[key] = path
+	}
+}
+
+// loadConfig 設定ファイルの値を、コマンドラインで指定されていないオプションに設定する
+// ユーザの設定ファイルより、プロジェクトの設定ファイルを優先する
+func loadConfig(flagSet *flag.FlagSet, profile string) error {
+
+	var files []*configFile
+
+	userPath, projectPath := configFilePaths()
+	for _, path := range []string{userPath, projectPath} {
+		if path == "" {
+			continue
+		}
+
+		file, err := readConfigFile(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		file.project = path == projectPath
+		files = append(files, file)
+	}
+
+	return applyConfig(flagSet, files, profile)
+}
+
+// configFilePaths ユーザの設定ファイルと、プロジェクトの設定ファイルのパスを返す (無い場合は空)
+func configFilePaths() (string, string) {
+
+	var userPath string
+
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		// ホームディレクトリが無い場合などは、ユーザの設定ファイルは無し
+		configDir, _ = os.UserConfigDir()
+	}
+	if configDir != "" {
+		userPath = filepath.Join(configDir, "filist", "config")
+	}
+
+	projectPath := findProjectConfig()
+	if projectPath == userPath {
+		projectPath = ""
+	}
+
+	return userPath, projectPath
+}
+
+// findProjectConfig カレントディレクトリから親ディレクトリへ順に、プロジェクトの設定ファイルを探す
+func findProjectConfig() string {
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	for {
+		path := filepath.Join(dir, projectConfigFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func readConfigFile(path string) (*configFile, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseConfig(path, f)
+}
+
+func parseConfig(path string, r io.Reader) (*configFile, error) {
+
+	file := &configFile{
+		path:     path,
+		profiles: map[string][]configValue{},
+	}
+
+	var profile string
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.TrimSpace(line[1:len(line)-1]) == "" {
+				return nil, fmt.Errorf("%s:%d: invalid profile: %s", path, lineNumber, line)
+			}
+			profile = strings.TrimSpace(line[1 : len(line)-1])
+			if _, ok := file.profiles[profile]; !ok {
+				file.profiles[profile] = nil
+			}
+			continue
+		}
+
+		key, value, hasValue := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("%s:%d: invalid line: %s", path, lineNumber, line)
+		}
+
+		configValue := configValue{
+			key:      key,
+			value:    strings.TrimSpace(value),
+			hasValue: hasValue,
+			line:     lineNumber,
+		}
+
+		if profile == "" {
+			file.defaults = append(file.defaults, configValue)
+		} else {
+			file.profiles[profile] = append(file.profiles[profile], configValue)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func applyConfig(flagSet *flag.FlagSet, files []*configFile, profile string) error {
+
+	values := newConfigValues()
+	profileFound := false
+
+	for _, file := range files {
+		if file.project {
+			if err := checkProjectConfig(file); err != nil {
+				return err
+			}
+		}
+
+		values.merge(file.path, file.defaults)
+
+		if profile != "" {
+			if profileValues, ok := file.profiles[profile]; ok {
+				// 同じファイルの中では、プロファイルの設定を優先する
+				values.merge(file.path, profileValues)
+				profileFound = true
+			}
+		}
+	}
+
+	if profile != "" && !profileFound {
+		return fmt.Errorf("profile not found: %s", profile)
+	}
+
+	// コマンドラインで指定されたオプション
+	commandLine := map[string]bool{}
+	flagSet.Visit(func(f *flag.Flag) {
+		commandLine[f.Name] = true
+	})
+
+	// 列はコマンドラインで選んだものだけにする (設定ファイルの列が混ざらないように)
+	registry := filist.NewRegistry()
+	columnsSelected := false
+	for name := range commandLine {
+		if isColumnFlag(registry, name) {
+			columnsSelected = true
+		}
+	}
+
+	for _, key := range values.keys {
+		if commandLine[key] || commandLine[exclusiveFlags[key]] {
+			// コマンドラインでの指定を優先
+			continue
+		}
+		if columnsSelected && isColumnFlag(registry, key) {
+			continue
+		}
+		if err := setConfigValues(flagSet, values.paths[key], key, values.values[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkProjectConfig プロジェクトの設定ファイルに、ユーザの設定ファイルでのみ指定できるオプションが無いか確認する
+func checkProjectConfig(file *configFile) error {
+
+	values := slices.Clone(file.defaults)
+	for _, profile := range slices.Sorted(maps.Keys(file.profiles)) {
+		values = append(values, file.profiles[profile]...)
+	}
+
+	for _, value := range values {
+		if userOnlyFlags[value.key] {
+			return fmt.Errorf("%s:%d: %s cannot be specified in project config file (use user config file or command line)", file.path, value.line, value.key)
+		}
+	}
+
+	return nil
+}
+
+func isColumnFlag(registry *filist.Registry, name string) bool {
+
+	if columnFlags[name] {
+		return true
+	}
+
+	// 列の名前と同じオプション
+	_, ok := registry.Lookup(name)
+	return ok
+}
+
+func setConfigValues(flagSet *flag.FlagSet, path string, key string, values []configValue) error {
+
+	line := values[0].line
+
+	switch key {
+	case "help", "profile", "no-config", "list-columns", "verify":
+		return fmt.Errorf("%s:%d: %s cannot be specified in config file", path, line, key)
+	}
+
+	f := flagSet.Lookup(key)
+	if f == nil {
+		return fmt.Errorf("%s:%d: unknown option: %s", path, line, key)
+	}
+
+	for _, value := range values {
+		v := value.value
+		if !value.hasValue {
+			if f.NoOptDefVal == "" {
+				return fmt.Errorf("%s:%d: value is required for %s", path, value.line, key)
+			}
+			v = f.NoOptDefVal
+		}
+
+		if err := flagSet.Set(key, v); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, value.line, err)
+		}
+	}
+
+	return nil
+}
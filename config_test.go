@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+
+	// ARRANGE
+	config := `
+# comment
+; comment
+size
+columns = rel, size:human
+
+[audit]
+hmac=sha256
+exec-column = name=basename {}
+[ empty ]
+`
+
+	// ACT
+	file, err := parseConfig("config", strings.NewReader(config))
+
+	// ASSERT
+	require.NoError(t, err)
+
+	assert.Equal(t, []configValue{
+		{key: "size", value: "", hasValue: false, line: 4},
+		{key: "columns", value: "rel, size:human", hasValue: true, line: 5},
+	}, file.defaults)
+	assert.Equal(t, map[string][]configValue{
+		"audit": {
+			{key: "hmac", value: "sha256", hasValue: true, line: 8},
+			{key: "exec-column", value: "name=basename {}", hasValue: true, line: 9},
+		},
+		"empty": nil,
+	}, file.profiles)
+}
+
+func TestParseConfig_Invalid(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		config   string
+		expected string
+	}{
+		{"profile", "size\n[audit\n", "config:2: invalid profile: [audit"},
+		{"empty profile", "[ ]\n", "config:1: invalid profile: [ ]"},
+		{"empty key", "= 1\n", "config:1: invalid line: = 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ACT
+			_, err := parseConfig("config", strings.NewReader(tt.config))
+
+			// ASSERT
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+
+	// ARRANGE
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	size := flagSet.Bool("size", false, "")
+	level := flagSet.Int("level", 0, "")
+	human := flagSet.Bool("human", false, "")
+	si := flagSet.Bool("si", false, "")
+	exec := flagSet.StringArray("exec-column", nil, "")
+
+	require.NoError(t, flagSet.Parse([]string{"--si"}))
+
+	user, err := parseConfig("user", strings.NewReader("size\nlevel = 1\nhuman\n[audit]\nexec-column = a=cmd {}\n"))
+	require.NoError(t, err)
+	project, err := parseConfig("project", strings.NewReader("level = 2\n[audit]\nlevel = 3\nexec-column = b=cmd {}\nexec-column = c=cmd {}\n"))
+	require.NoError(t, err)
+
+	// ACT
+	err = applyConfig(flagSet, []*configFile{user, project}, "audit")
+
+	// ASSERT
+	require.NoError(t, err)
+
+	assert.True(t, *size)
+	assert.Equal(t, 3, *level)
+	// コマンドラインで --si が指定されているので、--human は使わない
+	assert.False(t, *human)
+	assert.True(t, *si)
+	assert.Equal(t, []string{"b=cmd {}", "c=cmd {}"}, *exec)
+}
+
+func TestApplyConfig_CommandLinePrecedence(t *testing.T) {
+
+	// ARRANGE
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	exec := flagSet.StringArray("exec-column", nil, "")
+
+	require.NoError(t, flagSet.Parse([]string{"--exec-column", "x=cmd {}"}))
+
+	file, err := parseConfig("config", strings.NewReader("exec-column = a=cmd {}\n"))
+	require.NoError(t, err)
+
+	// ACT
+	err = applyConfig(flagSet, []*configFile{file}, "")
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x=cmd {}"}, *exec)
+}
+
+func TestApplyConfig_Error(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		config   string
+		profile  string
+		expected string
+	}{
+		{"unknown", "size\nxxx = 1\n", "", "config:2: unknown option: xxx"},
+		{"invalid value", "level = x\n", "", `config:1: invalid argument "x" for "--level" flag: strconv.ParseInt: parsing "x": invalid syntax`},
+		{"value required", "level\n", "", "config:1: value is required for level"},
+		{"profile", "profile = audit\n", "", "config:1: profile cannot be specified in config file"},
+		{"profile not found", "[audit]\nsize\n", "daily", "profile not found: daily"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ARRANGE
+			flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+			flagSet.Bool("size", false, "")
+			flagSet.Int("level", 0, "")
+			flagSet.String("profile", "", "")
+			require.NoError(t, flagSet.Parse(nil))
+
+			file, err := parseConfig("config", strings.NewReader(tt.config))
+			require.NoError(t, err)
+
+			// ACT
+			err = applyConfig(flagSet, []*configFile{file}, tt.profile)
+
+			// ASSERT
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
@@ -0,0 +1,567 @@
+package filist
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// アーカイブの種類
+type archiveKind int
+
+const (
+	archiveNone archiveKind = iota
+	archiveZip
+	archiveTar
+	archiveTarGz
+)
+
+func detectArchiveKind(name string) archiveKind {
+
+	lower := strings.ToLower(name)
+
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveZip
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveTar
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveTarGz
+	default:
+		return archiveNone
+	}
+}
+
+// openArchive アーカイブを開いて、その中身を fs.FS として返す
+func openArchive(kind archiveKind, open func() (io.ReadCloser, error)) (fs.FS, func(), error) {
+
+	f, err := open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch kind {
+	case archiveZip:
+		ra, size, err := toReaderAt(f)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		zr, err := zip.NewReader(ra, size)
+		if err != nil {
+			ra.Close()
+			return nil, nil, err
+		}
+
+		return zr, func() { ra.Close() }, nil
+
+	case archiveTar, archiveTarGz:
+		tfs, err := newTarFS(kind, f, open)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return tfs, func() { tfs.Close() }, nil
+	}
+
+	f.Close()
+	return nil, nil, errors.New("unsupported archive")
+}
+
+type readerAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// toReaderAt zipはランダムアクセスが必要なので、できない場合は一時ファイルに書き出す
+// 返却したものを閉じると、元のファイルも閉じる
+func toReaderAt(f io.ReadCloser) (readerAtCloser, int64, error) {
+
+	if ra, ok := f.(interface {
+		readerAtCloser
+		Stat() (fs.FileInfo, error)
+	}); ok {
+		info, err := ra.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return ra, info.Size(), nil
+	}
+
+	defer f.Close()
+
+	spool, err := newSpoolFile()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size, err := io.Copy(spool, f)
+	if err != nil {
+		spool.Close()
+		return nil, 0, err
+	}
+
+	return spool, size, nil
+}
+
+// spoolFile 閉じると削除される一時ファイル
+type spoolFile struct {
+	*os.File
+}
+
+func newSpoolFile() (*spoolFile, error) {
+
+	f, err := os.CreateTemp("", "filist-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &spoolFile{f}, nil
+}
+
+func (f *spoolFile) Close() error {
+
+	err := f.File.Close()
+	if removeErr := os.Remove(f.Name()); err == nil {
+		err = removeErr
+	}
+
+	return err
+}
+
+// tarFS tarの中身を fs.FS として扱う
+// 最初はヘッダだけを読み込み、ファイルの内容は読む必要が出てから取得する
+// 非圧縮のtarでランダムアクセスできる場合は元のファイルから直接読み、
+// そうでない場合は内容が初めて読まれた時点で一時ファイルに書き出す
+type tarFS struct {
+	entries map[string]*tarEntry
+	kind    archiveKind
+	open    func() (io.ReadCloser, error)
+	// ファイルの内容の読み込み元 (まだ一時ファイルに書き出していない場合は nil)
+	data    io.ReaderAt
+	closers []io.Closer
+}
+
+type tarEntry struct {
+	name string
+	// ディレクトリが明示的に含まれていない場合は nil
+	header *tar.Header
+	// ハードリンクの場合のリンク先
+	target   *tarEntry
+	offset   int64
+	size     int64
+	children []string
+}
+
+func newTarFS(kind archiveKind, f io.ReadCloser, open func() (io.ReadCloser, error)) (*tarFS, error) {
+
+	tfs := &tarFS{
+		entries: map[string]*tarEntry{
+			".": {name: "."},
+		},
+		kind: kind,
+		open: open,
+	}
+
+	if err := tfs.load(f); err != nil {
+		f.Close()
+		tfs.Close()
+		return nil, err
+	}
+
+	if tfs.data == nil {
+		// 内容は後から開き直して読むので、ここでは閉じておく
+		f.Close()
+	}
+
+	return tfs, nil
+}
+
+// load ヘッダを読み込んで、エントリの一覧を作る
+func (t *tarFS) load(f io.ReadCloser) error {
+
+	var r io.Reader = f
+
+	ra, size, seekable := readerAtOf(f)
+	if seekable && t.kind == archiveTar {
+		// 内容は読み飛ばして、後から位置を指定して読む
+		r = io.NewSectionReader(ra, 0, size)
+	} else {
+		seekable = false
+	}
+
+	tr, closeReader, err := newTarReader(t.kind, r)
+	if err != nil {
+		return err
+	}
+	defer closeReader()
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name, ok := tarEntryName(header)
+		if !ok {
+			continue
+		}
+
+		entry := t.ensureEntry(name)
+		entry.header = header
+		entry.target = nil
+
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeGNUSparse:
+			entry.size = header.Size
+			if seekable {
+				if isSparse(header) {
+					// 内容が連続していないので、元のファイルからは直接読めない
+					seekable = false
+					continue
+				}
+
+				offset, err := r.(io.Seeker).Seek(0, io.SeekCurrent)
+				if err != nil {
+					return err
+				}
+				entry.offset = offset
+			}
+
+		case tar.TypeSymlink:
+			// シンボリックリンクはリンク先を内容とする (zipと同じ)
+			entry.size = int64(len(header.Linkname))
+
+		case tar.TypeLink:
+			// ハードリンクはリンク先と同じ内容
+			if target, ok := t.entries[path.Clean(strings.TrimPrefix(header.Linkname, "/"))]; ok && target.header != nil {
+				if target.target != nil {
+					target = target.target
+				}
+				entry.target = target
+				entry.size = target.size
+			}
+			header.Typeflag = tar.TypeReg
+			header.Size = entry.size
+		}
+	}
+
+	for _, entry := range t.entries {
+		sort.Strings(entry.children)
+	}
+
+	if seekable {
+		t.data = ra
+		t.closers = append(t.closers, f)
+	}
+
+	return nil
+}
+
+// spool ファイルの内容を先頭から読み直して、一時ファイルに書き出す
+func (t *tarFS) spool() error {
+
+	f, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr, closeReader, err := newTarReader(t.kind, f)
+	if err != nil {
+		return err
+	}
+	defer closeReader()
+
+	spool, err := newSpoolFile()
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			spool.Close()
+			return err
+		}
+
+		name, ok := tarEntryName(header)
+		if !ok || (header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeGNUSparse) {
+			continue
+		}
+
+		// 同じ名前が複数ある場合は、ヘッダの読み込み時と同じく後のものが優先される
+		size, err := io.Copy(spool, tr)
+		if err != nil {
+			spool.Close()
+			return err
+		}
+
+		entry := t.entries[name]
+		entry.offset = offset
+		entry.size = size
+		offset += size
+	}
+
+	t.data = spool
+	t.closers = append(t.closers, spool)
+
+	return nil
+}
+
+// content ファイルの内容 (ディレクトリなど内容を持たないものは空)
+func (t *tarFS) content(entry *tarEntry) (*io.SectionReader, error) {
+
+	if entry.target != nil {
+		entry = entry.target
+	}
+
+	if entry.header == nil {
+		return io.NewSectionReader(strings.NewReader(""), 0, 0), nil
+	}
+
+	switch entry.header.Typeflag {
+	case tar.TypeSymlink:
+		return io.NewSectionReader(strings.NewReader(entry.header.Linkname), 0, entry.size), nil
+
+	case tar.TypeReg, tar.TypeGNUSparse:
+		if entry.size == 0 {
+			break
+		}
+
+		if t.data == nil {
+			if err := t.spool(); err != nil {
+				return nil, err
+			}
+		}
+		return io.NewSectionReader(t.data, entry.offset, entry.size), nil
+	}
+
+	return io.NewSectionReader(strings.NewReader(""), 0, 0), nil
+}
+
+// newTarReader 圧縮されている場合は展開しながら読む
+func newTarReader(kind archiveKind, r io.Reader) (*tar.Reader, func(), error) {
+
+	if kind == archiveTarGz {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tar.NewReader(gr), func() { gr.Close() }, nil
+	}
+
+	return tar.NewReader(r), func() {}, nil
+}
+
+// readerAtOf ランダムアクセスできるファイルであれば、その ReaderAt とサイズを返す
+func readerAtOf(f io.Reader) (io.ReaderAt, int64, bool) {
+
+	ra, ok := f.(statReaderAt)
+	if !ok {
+		return nil, 0, false
+	}
+
+	info, err := ra.Stat()
+	if err != nil {
+		return nil, 0, false
+	}
+
+	return ra, info.Size(), true
+}
+
+func tarEntryName(header *tar.Header) (string, bool) {
+
+	name := path.Clean(strings.TrimPrefix(header.Name, "/"))
+	if name == "." || !fs.ValidPath(name) {
+		// 親ディレクトリを指すような不正なパスは対象外
+		return "", false
+	}
+
+	return name, true
+}
+
+// isSparse スパースファイルは内容がtar内で連続していない
+func isSparse(header *tar.Header) bool {
+
+	if header.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+
+	for key := range header.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *tarFS) ensureEntry(name string) *tarEntry {
+
+	if entry, ok := t.entries[name]; ok {
+		return entry
+	}
+
+	entry := &tarEntry{name: name}
+	t.entries[name] = entry
+
+	parent := t.ensureEntry(path.Dir(name))
+	parent.children = append(parent.children, path.Base(name))
+
+	return entry
+}
+
+func (t *tarFS) Close() error {
+
+	var err error
+	for _, closer := range t.closers {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	t.closers = nil
+
+	return err
+}
+
+func (t *tarFS) lookup(op string, name string) (*tarEntry, error) {
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	entry, ok := t.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return entry, nil
+}
+
+func (t *tarFS) Open(name string) (fs.File, error) {
+
+	entry, err := t.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := t.content(entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return &tarFile{
+		fsys:          t,
+		entry:         entry,
+		SectionReader: content,
+	}, nil
+}
+
+// Stat 内容を読まずに済むように、開かずに情報を返す
+func (t *tarFS) Stat(name string) (fs.FileInfo, error) {
+
+	entry, err := t.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.info(), nil
+}
+
+func (t *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+
+	entry, err := t.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !entry.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	entries := make([]fs.DirEntry, len(entry.children))
+	for i, child := range entry.children {
+		entries[i] = fs.FileInfoToDirEntry(t.entries[path.Join(name, child)].info())
+	}
+
+	return entries, nil
+}
+
+func (e *tarEntry) isDir() bool {
+	return e.header == nil || e.header.Typeflag == tar.TypeDir
+}
+
+func (e *tarEntry) info() fs.FileInfo {
+
+	if e.header == nil {
+		return implicitDirInfo{name: path.Base(e.name)}
+	}
+
+	return e.header.FileInfo()
+}
+
+// tarFile tar内のファイル
+type tarFile struct {
+	*io.SectionReader
+	fsys     *tarFS
+	entry    *tarEntry
+	dirIndex int
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) {
+	return f.entry.info(), nil
+}
+
+func (f *tarFile) Close() error {
+	return nil
+}
+
+func (f *tarFile) ReadDir(n int) ([]fs.DirEntry, error) {
+
+	entries, err := f.fsys.ReadDir(f.entry.name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries = entries[f.dirIndex:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(entries) {
+			entries = entries[:n]
+		}
+	}
+	f.dirIndex += len(entries)
+
+	return entries, nil
+}
+
+// implicitDirInfo tarに明示的に含まれていないディレクトリ
+type implicitDirInfo struct {
+	name string
+}
+
+func (i implicitDirInfo) Name() string       { return i.name }
+func (i implicitDirInfo) Size() int64        { return 0 }
+func (i implicitDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0755 }
+func (i implicitDirInfo) ModTime() time.Time { return time.Time{} }
+func (i implicitDirInfo) IsDir() bool        { return true }
+func (i implicitDirInfo) Sys() any           { return nil }
@@ -0,0 +1,451 @@
+package filist
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectArchiveKind(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		expected archiveKind
+	}{
+		{"a.zip", archiveZip},
+		{"A.ZIP", archiveZip},
+		{"a.tar", archiveTar},
+		{"a.tar.gz", archiveTarGz},
+		{"a.tgz", archiveTarGz},
+		{"a.gz", archiveNone},
+		{"a.txt", archiveNone},
+		{"zip", archiveNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, detectArchiveKind(tt.name))
+		})
+	}
+}
+
+func TestOpenArchive_zip(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	archivePath := filepath.Join(temp, "a.zip")
+	writeArchiveFile(t, archivePath, createZip(t, map[string]string{
+		"a.txt":     "A",
+		"d/b.txt":   "BB",
+		"d/e/c.txt": "CCC",
+	}))
+
+	// ACT
+	fsys, closeArchive, err := openArchive(archiveZip, openOsFile(archivePath))
+
+	// ASSERT
+	require.NoError(t, err)
+	defer closeArchive()
+
+	assert.NoError(t, fstest.TestFS(fsys, "a.txt", "d/b.txt", "d/e/c.txt"))
+}
+
+func TestOpenArchive_tar(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	archivePath := filepath.Join(temp, "a.tar")
+	writeArchiveFile(t, archivePath, createTar(t, map[string]string{
+		"a.txt":     "A",
+		"d/b.txt":   "BB",
+		"d/e/c.txt": "CCC",
+	}))
+
+	// ACT
+	fsys, closeArchive, err := openArchive(archiveTar, openOsFile(archivePath))
+
+	// ASSERT
+	require.NoError(t, err)
+	defer closeArchive()
+
+	assert.NoError(t, fstest.TestFS(fsys, "a.txt", "d/b.txt", "d/e/c.txt"))
+
+	data, err := fs.ReadFile(fsys, "d/e/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "CCC", string(data))
+}
+
+func TestOpenArchive_tarGz(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	archivePath := filepath.Join(temp, "a.tar.gz")
+	writeArchiveFile(t, archivePath, gzipData(t, createTar(t, map[string]string{
+		"a.txt":   "A",
+		"d/b.txt": "BB",
+	})))
+
+	// ACT
+	fsys, closeArchive, err := openArchive(archiveTarGz, openOsFile(archivePath))
+
+	// ASSERT
+	require.NoError(t, err)
+	defer closeArchive()
+
+	assert.NoError(t, fstest.TestFS(fsys, "a.txt", "d/b.txt"))
+}
+
+func TestOpenArchive_invalid(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	archivePath, _ := setupFile(t, temp, "a.zip", "not zip", "")
+
+	// ACT
+	_, _, err := openArchive(archiveZip, openOsFile(archivePath))
+
+	// ASSERT
+	assert.Error(t, err)
+}
+
+func TestNewTarFS_links(t *testing.T) {
+
+	// ARRANGE
+	buf := new(bytes.Buffer)
+	w := tar.NewWriter(buf)
+	writeTarHeader(t, w, &tar.Header{Name: "a.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 3}, "ABC")
+	writeTarHeader(t, w, &tar.Header{Name: "hard", Typeflag: tar.TypeLink, Linkname: "a.txt"}, "")
+	writeTarHeader(t, w, &tar.Header{Name: "soft", Typeflag: tar.TypeSymlink, Linkname: "a.txt"}, "")
+	writeTarHeader(t, w, &tar.Header{Name: "../evil.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 1}, "X")
+	writeTarHeader(t, w, &tar.Header{Name: "dup.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 3}, "OLD")
+	writeTarHeader(t, w, &tar.Header{Name: "dup.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 3}, "NEW")
+	require.NoError(t, w.Close())
+
+	// ACT
+	// ランダムアクセスできない読み込み元のため、内容は一時ファイルに書き出される
+	tfs, err := newTarFS(archiveTar, io.NopCloser(bytes.NewReader(buf.Bytes())), openBytes(buf.Bytes()))
+
+	// ASSERT
+	require.NoError(t, err)
+	defer tfs.Close()
+
+	// ハードリンクはリンク先と同じ内容
+	data, err := fs.ReadFile(tfs, "hard")
+	require.NoError(t, err)
+	assert.Equal(t, "ABC", string(data))
+
+	info, err := fs.Stat(tfs, "hard")
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	assert.Equal(t, int64(3), info.Size())
+
+	// シンボリックリンクはリンク先が内容
+	data, err = fs.ReadFile(tfs, "soft")
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt", string(data))
+
+	info, err = fs.Stat(tfs, "soft")
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&fs.ModeSymlink)
+
+	// 同じ名前は後のものが優先
+	data, err = fs.ReadFile(tfs, "dup.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "NEW", string(data))
+
+	// 不正なパスは含めない
+	entries, err := fs.ReadDir(tfs, ".")
+	require.NoError(t, err)
+	assert.Len(t, entries, 4)
+}
+
+func TestOpenArchive_tar_noSpool(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	archivePath := filepath.Join(temp, "a.tar")
+	writeArchiveFile(t, archivePath, createTar(t, map[string]string{
+		"a.txt":   "A",
+		"d/b.txt": "BB",
+	}))
+
+	spoolDir := setupSpoolDir(t)
+
+	// ACT
+	fsys, closeArchive, err := openArchive(archiveTar, openOsFile(archivePath))
+
+	// ASSERT
+	require.NoError(t, err)
+	defer closeArchive()
+
+	data, err := fs.ReadFile(fsys, "d/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "BB", string(data))
+
+	// 元のファイルから直接読むので、一時ファイルは作られない
+	assertDirEmpty(t, spoolDir)
+}
+
+func TestOpenArchive_tarGz_spoolOnRead(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	archivePath := filepath.Join(temp, "a.tar.gz")
+	writeArchiveFile(t, archivePath, gzipData(t, createTar(t, map[string]string{
+		"a.txt":   "A",
+		"d/b.txt": "BB",
+	})))
+
+	spoolDir := setupSpoolDir(t)
+
+	// ACT
+	fsys, closeArchive, err := openArchive(archiveTarGz, openOsFile(archivePath))
+
+	// ASSERT
+	require.NoError(t, err)
+
+	// 内容を読まない限り、一時ファイルは作られない
+	entries, err := fs.ReadDir(fsys, "d")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	info, err := fs.Stat(fsys, "d/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), info.Size())
+	assertDirEmpty(t, spoolDir)
+
+	// 内容を読むと一時ファイルに書き出される
+	data, err := fs.ReadFile(fsys, "d/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "BB", string(data))
+	data, err = fs.ReadFile(fsys, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "A", string(data))
+
+	spooled, err := os.ReadDir(spoolDir)
+	require.NoError(t, err)
+	assert.Len(t, spooled, 1)
+
+	// 閉じると削除される
+	closeArchive()
+	assertDirEmpty(t, spoolDir)
+}
+
+func TestOpenArchive_tar_sparse(t *testing.T) {
+
+	// ARRANGE
+	// PAX形式のスパースファイル (GNU sparse 1.0) は内容が連続していない
+	// tar.Writer はスパースファイルを書けないので、拡張ヘッダを直接組み立てる
+	buf := new(bytes.Buffer)
+	w := tar.NewWriter(buf)
+	writeTarHeader(t, w, &tar.Header{Name: "a.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 1}, "A")
+	require.NoError(t, w.Flush())
+
+	records := paxRecord("GNU.sparse.major", "1") +
+		paxRecord("GNU.sparse.minor", "0") +
+		paxRecord("GNU.sparse.name", "s.bin") +
+		paxRecord("GNU.sparse.realsize", "6")
+	writeTarHeader(t, w, &tar.Header{Name: "PaxHeaders/s.bin", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(records)), Format: tar.FormatUSTAR}, records)
+	require.NoError(t, w.Flush())
+	setTarTypeflag(buf.Bytes()[1024:1536], tar.TypeXHeader)
+
+	// スパースマップ (1エントリ: オフセット3から3バイト) の後にデータが続く
+	sparseMap := "1\n3\n3\n"
+	writeTarHeader(t, w, &tar.Header{Name: "GNUSparseFile.0/s.bin", Typeflag: tar.TypeReg, Mode: 0644, Size: 512 + 3, Format: tar.FormatUSTAR},
+		sparseMap+strings.Repeat("\x00", 512-len(sparseMap))+"XYZ")
+	require.NoError(t, w.Close())
+
+	temp := t.TempDir()
+	archivePath := filepath.Join(temp, "a.tar")
+	writeArchiveFile(t, archivePath, buf.Bytes())
+
+	// ACT
+	fsys, closeArchive, err := openArchive(archiveTar, openOsFile(archivePath))
+
+	// ASSERT
+	require.NoError(t, err)
+	defer closeArchive()
+
+	data, err := fs.ReadFile(fsys, "s.bin")
+	require.NoError(t, err)
+	assert.Equal(t, "\x00\x00\x00XYZ", string(data))
+
+	data, err = fs.ReadFile(fsys, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "A", string(data))
+}
+
+func TestReadDir_archive(t *testing.T) {
+
+	// ARRANGE
+	fsys := fstest.MapFS{
+		"d/a.txt": {Data: []byte("A")},
+		"d/b.txt": {Data: []byte("BB")},
+	}
+	dirInfo, err := fs.Stat(fsys, "d")
+	require.NoError(t, err)
+
+	// ACT
+	entries, err := readDir("x.zip/d", newFSFileInfo(fsys, "d", dirInfo))
+
+	// ASSERT
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	info, err := entries[1].Info()
+	require.NoError(t, err)
+
+	f, err := openFile("x.zip/d/b.txt", info)
+	require.NoError(t, err)
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "BB", string(data))
+}
+
+func createZip(t *testing.T, files map[string]string) []byte {
+
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+
+	for _, name := range sortedKeys(files) {
+		f, err := w.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		})
+		require.NoError(t, err)
+
+		_, err = io.WriteString(f, files[name])
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func createTar(t *testing.T, files map[string]string) []byte {
+
+	buf := new(bytes.Buffer)
+	w := tar.NewWriter(buf)
+
+	for _, name := range sortedKeys(files) {
+		writeTarHeader(t, w, &tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(files[name])),
+			ModTime:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		}, files[name])
+	}
+
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func writeTarHeader(t *testing.T, w *tar.Writer, header *tar.Header, contents string) {
+
+	require.NoError(t, w.WriteHeader(header))
+
+	_, err := io.WriteString(w, contents)
+	require.NoError(t, err)
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func writeArchiveFile(t *testing.T, filePath string, data []byte) {
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0777))
+	require.NoError(t, os.WriteFile(filePath, data, 0666))
+}
+
+func openOsFile(filePath string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return os.Open(filePath)
+	}
+}
+
+func paxRecord(key string, value string) string {
+
+	// 長さには、長さを表す数字自身も含まれる
+	record := " " + key + "=" + value + "\n"
+	size := len(record)
+	for size != len(strconv.Itoa(size))+len(record) {
+		size = len(strconv.Itoa(size)) + len(record)
+	}
+
+	return strconv.Itoa(size) + record
+}
+
+// setTarTypeflag ヘッダブロックの種類を書き換えて、チェックサムを再計算する
+func setTarTypeflag(block []byte, typeflag byte) {
+
+	block[156] = typeflag
+
+	copy(block[148:156], "        ")
+	var sum int64
+	for _, b := range block {
+		sum += int64(b)
+	}
+	copy(block[148:156], fmt.Sprintf("%06o\x00 ", sum))
+}
+
+func openBytes(data []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+// setupSpoolDir 一時ファイルの書き出し先を、テスト用のディレクトリに変える
+func setupSpoolDir(t *testing.T) string {
+
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	t.Setenv("TMP", dir)
+	t.Setenv("TEMP", dir)
+
+	return dir
+}
+
+func assertDirEmpty(t *testing.T, dir string) {
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func sortedKeys(m map[string]string) []string {
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,27 @@
+//go:build linux && (amd64 || arm64)
+
+package filist
+
+import (
+	"os"
+	"syscall"
+)
+
+// DropCacheSupported 読み込んだファイルのページキャッシュを破棄できるプラットフォームか
+const DropCacheSupported = true
+
+const posixFadvDontneed = 4
+
+// dropCache ファイルの指定範囲のページキャッシュを、不要であるとOSに伝える (posix_fadvise の POSIX_FADV_DONTNEED)
+// 失敗してもキャッシュが残るだけなので、エラーは無視する
+func dropCache(f *os.File, offset int64, length int64) {
+
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return
+	}
+
+	conn.Control(func(fd uintptr) {
+		syscall.Syscall6(syscall.SYS_FADVISE64, fd, uintptr(offset), uintptr(length), posixFadvDontneed, 0, 0)
+	})
+}
@@ -0,0 +1,14 @@
+//go:build !linux || !(amd64 || arm64)
+
+package filist
+
+import (
+	"os"
+)
+
+// DropCacheSupported 読み込んだファイルのページキャッシュを破棄できるプラットフォームか
+const DropCacheSupported = false
+
+// dropCache ファイルの指定範囲のページキャッシュを、不要であるとOSに伝える
+func dropCache(f *os.File, offset int64, length int64) {
+}
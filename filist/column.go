@@ -0,0 +1,402 @@
+package filist
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ColumnType 列の値の種類
+type ColumnType int
+
+const (
+	ColumnTypeString ColumnType = iota
+	ColumnTypeNumber
+	ColumnTypeTime
+)
+
+func (t ColumnType) String() string {
+
+	switch t {
+	case ColumnTypeNumber:
+		return "number"
+	case ColumnTypeTime:
+		return "time"
+	default:
+		return "string"
+	}
+}
+
+// Column 一覧に表示する列
+type Column interface {
+	// Name 列の名前
+	Name() string
+	// Type 値の種類
+	Type() ColumnType
+	// Value エントリに対する値 (ディレクトリなど値が無い場合は空文字)
+	Value(entry *Entry) (string, error)
+}
+
+// funcColumn 関数で値を計算する列
+type funcColumn struct {
+	name       string
+	columnType ColumnType
+	value      func(entry *Entry) (string, error)
+}
+
+// NewColumn 関数で値を計算する列を作成する
+func NewColumn(name string, columnType ColumnType, value func(entry *Entry) (string, error)) Column {
+	return &funcColumn{
+		name:       name,
+		columnType: columnType,
+		value:      value,
+	}
+}
+
+func (c *funcColumn) Name() string {
+	return c.name
+}
+
+func (c *funcColumn) Type() ColumnType {
+	return c.columnType
+}
+
+func (c *funcColumn) Value(entry *Entry) (string, error) {
+	return c.value(entry)
+}
+
+// fileColumn ファイルのパスと情報から値を計算する関数を列にする
+func fileColumn(name string, columnType ColumnType, value func(string, string, os.FileInfo) (string, error)) Column {
+
+	return NewColumn(name, columnType, func(entry *Entry) (string, error) {
+		return value(entry.BaseDir, entry.Path, entry.Info)
+	})
+}
+
+// Values エントリに対する各列の値を返す
+func Values(entry *Entry, columns []Column) ([]string, error) {
+
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		value, err := column.Value(entry)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+// RelPathColumn ベースディレクトリからの相対パス (ディレクトリは末尾に区切り文字)
+func RelPathColumn() Column {
+	return fileColumn("rel", ColumnTypeString, getRelPath)
+}
+
+// AbsPathColumn 絶対パス (ディレクトリは末尾に区切り文字)
+func AbsPathColumn() Column {
+	return fileColumn("abs", ColumnTypeString, getAbsPath)
+}
+
+// RootColumn 引数で指定されたルート
+func RootColumn() Column {
+
+	return NewColumn("root", ColumnTypeString, func(entry *Entry) (string, error) {
+		return entry.Root.Name, nil
+	})
+}
+
+// RootAbsPathColumn ルートの絶対パス
+func RootAbsPathColumn() Column {
+
+	return NewColumn("root-abs", ColumnTypeString, func(entry *Entry) (string, error) {
+		return entry.Root.AbsPath, nil
+	})
+}
+
+// DepthColumn ルートからの階層
+func DepthColumn() Column {
+
+	return NewColumn("depth", ColumnTypeNumber, func(entry *Entry) (string, error) {
+		if entry.Depth < 0 {
+			return "", nil
+		}
+		return fmt.Sprint(entry.Depth), nil
+	})
+}
+
+// SizeColumn ファイルサイズ
+func SizeColumn(format SizeFormat, precision int) Column {
+
+	if format == SizeFormatBytes {
+		return fileColumn("size", ColumnTypeNumber, getSize)
+	}
+
+	// 単位付きの表示は数値としては扱えない
+	return fileColumn("size", ColumnTypeString, getHumanSize(format, precision))
+}
+
+// MtimeColumn 更新日時
+func MtimeColumn() Column {
+	return fileColumn("mtime", ColumnTypeTime, getMtime)
+}
+
+// UnixMtimeColumn 更新日時 (UNIX時間の秒数)
+func UnixMtimeColumn() Column {
+	return fileColumn("mtime", ColumnTypeNumber, getUnixMtime)
+}
+
+// SizeFormat サイズの表示形式
+type SizeFormat int
+
+const (
+	SizeFormatBytes SizeFormat = iota
+	SizeFormatIEC
+	SizeFormatSI
+)
+
+func getRelPath(baseDir string, filePath string, info os.FileInfo) (string, error) {
+
+	relPath, err := filepath.Rel(baseDir, filePath)
+	if err != nil {
+		return "", err
+	}
+
+	if info.IsDir() {
+		relPath = relPath + string(filepath.Separator)
+	}
+
+	return relPath, nil
+}
+
+func getAbsPath(baseDir string, filePath string, info os.FileInfo) (string, error) {
+
+	if info.IsDir() {
+		filePath = filePath + string(filepath.Separator)
+	}
+
+	return filePath, nil
+}
+
+func getSize(baseDir string, filePath string, info os.FileInfo) (string, error) {
+
+	if info.IsDir() {
+		return "", nil
+	}
+
+	return fmt.Sprint(info.Size()), nil
+}
+
+func getHumanSize(format SizeFormat, precision int) func(string, string, os.FileInfo) (string, error) {
+
+	return func(baseDir string, filePath string, info os.FileInfo) (string, error) {
+
+		if info.IsDir() {
+			return "", nil
+		}
+
+		return FormatSize(info.Size(), format, precision), nil
+	}
+}
+
+// FormatSize サイズを指定の形式で表示する
+func FormatSize(size int64, format SizeFormat, precision int) string {
+
+	var base float64
+	var units []string
+
+	switch format {
+	case SizeFormatIEC:
+		base = 1024
+		units = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	case SizeFormatSI:
+		base = 1000
+		units = []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+	default:
+		return fmt.Sprint(size)
+	}
+
+	if float64(size) < base {
+		return fmt.Sprintf("%d %s", size, units[0])
+	}
+
+	value := float64(size)
+	unit := 0
+	scale := math.Pow(10, float64(precision))
+
+	for unit < len(units)-1 {
+		// 丸めた結果で次の単位に繰り上がる場合(1023.99 KiB -> 1.0 MiB)も考慮
+		if math.Round(value*scale)/scale < base {
+			break
+		}
+		value /= base
+		unit++
+	}
+
+	return strconv.FormatFloat(value, 'f', precision, 64) + " " + units[unit]
+}
+
+func getMtime(baseDir string, filePath string, info os.FileInfo) (string, error) {
+
+	if info.IsDir() {
+		return "", nil
+	}
+
+	return info.ModTime().Format("2006-01-02T15:04:05.000000-07:00"), nil
+}
+
+func getUnixMtime(baseDir string, filePath string, info os.FileInfo) (string, error) {
+
+	if info.IsDir() {
+		return "", nil
+	}
+
+	return strconv.FormatInt(info.ModTime().Unix(), 10), nil
+}
+
+// readContent ファイルを1回だけ読み込み、その内容を全てのwriterに渡す
+// 大きなファイルの途中でも中断できるように、読み込みごとにコンテキストを確認する
+// コンテキストに Throttle が設定されていれば、その上限を超えないように読み込む
+func readContent(ctx context.Context, filePath string, info os.FileInfo, writers ...io.Writer) error {
+
+	f, err := openContent(ctx, filePath, info)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(io.MultiWriter(writers...), f)
+	return err
+}
+
+// hasContent 内容を読むエントリか
+// デバイスやパイプなどは、開いたり読み込んだりしたまま終わらないことがあるため内容を読まない
+func hasContent(filePath string, info os.FileInfo) bool {
+
+	mode := info.Mode()
+	if mode&os.ModeSymlink != 0 {
+		if !onOSFileSystem(info) {
+			// アーカイブ内のシンボリックリンクは、リンク先の名前が内容
+			return true
+		}
+
+		// OS上のシンボリックリンクはリンク先の内容を読む
+		target, err := os.Stat(filePath)
+		if err != nil {
+			// リンク切れなどは、開く際のエラーとして返す
+			return true
+		}
+		mode = target.Mode()
+	}
+
+	return mode.IsRegular()
+}
+
+// openContent 内容を読むために開く
+// 読み込みはコンテキストで中断し、コンテキストに速度の上限があれば適用する
+// ランダムアクセスできるもの(アーカイブとして開くファイルなど)は、ReadAt にも同じく適用する
+func openContent(ctx context.Context, filePath string, info os.FileInfo) (io.ReadCloser, error) {
+
+	f, err := openFile(filePath, info)
+	if err != nil {
+		return nil, err
+	}
+
+	throttle := throttleFromContext(ctx)
+	if fsInfo, ok := info.(*fsFileInfo); ok && fsInfo.inArchive {
+		// アーカイブ内のエントリは、アーカイブ自体の読み込みで制限済み
+		throttle = nil
+	}
+	file, _ := f.(*os.File)
+
+	var r io.Reader = &contextReader{ctx: ctx, r: f}
+	if throttle != nil {
+		// 読み込みの速度の上限 (OS上のファイルであれば、読み込んだ後にページキャッシュを破棄する)
+		r = throttle.reader(ctx, r, file)
+	}
+	content := &contentReader{Reader: r, f: f}
+
+	if ra, ok := f.(statReaderAt); ok {
+		return &contentFile{
+			contentReader: content,
+			ra:            ra,
+			ctx:           ctx,
+			throttle:      throttle,
+			file:          file,
+		}, nil
+	}
+
+	return content, nil
+}
+
+type contentReader struct {
+	io.Reader
+	f io.ReadCloser
+}
+
+func (r *contentReader) Close() error {
+	return r.f.Close()
+}
+
+type statReaderAt interface {
+	io.ReaderAt
+	Stat() (fs.FileInfo, error)
+}
+
+// contentFile ランダムアクセスできる内容
+type contentFile struct {
+	*contentReader
+	ra       statReaderAt
+	ctx      context.Context
+	throttle *Throttle
+	file     *os.File
+}
+
+func (f *contentFile) ReadAt(p []byte, off int64) (int, error) {
+
+	if err := f.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := f.ra.ReadAt(p, off)
+	if n > 0 && f.throttle != nil {
+		if err := f.throttle.bytes.wait(f.ctx, n); err != nil {
+			return n, err
+		}
+	}
+
+	return n, err
+}
+
+func (f *contentFile) Stat() (fs.FileInfo, error) {
+	return f.ra.Stat()
+}
+
+func (f *contentFile) Close() error {
+
+	if f.throttle != nil && f.throttle.dropCache && f.file != nil {
+		// 読んだ範囲がばらばらなので、閉じる時にファイル全体のページキャッシュを破棄する
+		dropCache(f.file, 0, 0)
+	}
+
+	return f.contentReader.Close()
+}
+
+// contextReader コンテキストがキャンセルされたら、以降の読み込みをエラーにする
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
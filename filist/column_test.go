@@ -0,0 +1,231 @@
+package filist
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelPath(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	// ACT
+	result, err := getRelPath(temp, filePath, info)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "hoge.txt", result)
+}
+
+func TestAbsPath(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	// ACT
+	result, err := getAbsPath(temp, filePath, info)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, filePath, result)
+}
+
+func TestGetSize(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	// ACT
+	result, err := getSize(temp, filePath, info)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "7", result)
+}
+
+func TestGetHumanSize(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", strings.Repeat("x", 2048), "")
+
+	// ACT
+	result, err := getHumanSize(SizeFormatIEC, 1)(temp, filePath, info)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "2.0 KiB", result)
+}
+
+func TestGetHumanSize_dir(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	info, err := os.Stat(temp)
+	require.NoError(t, err)
+
+	// ACT
+	result, err := getHumanSize(SizeFormatIEC, 1)(temp, temp, info)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "", result)
+}
+
+func TestFormatSize(t *testing.T) {
+
+	assert.Equal(t, "0 B", FormatSize(0, SizeFormatIEC, 1))
+	assert.Equal(t, "1023 B", FormatSize(1023, SizeFormatIEC, 1))
+	assert.Equal(t, "1.0 KiB", FormatSize(1024, SizeFormatIEC, 1))
+	assert.Equal(t, "1 KiB", FormatSize(1500, SizeFormatIEC, 0))
+	assert.Equal(t, "1.465 KiB", FormatSize(1500, SizeFormatIEC, 3))
+	// 丸めで繰り上がる場合は上位の単位
+	assert.Equal(t, "1.0 MiB", FormatSize(1024*1024-1, SizeFormatIEC, 1))
+	assert.Equal(t, "8.0 EiB", FormatSize(math.MaxInt64, SizeFormatIEC, 1))
+
+	assert.Equal(t, "999 B", FormatSize(999, SizeFormatSI, 1))
+	assert.Equal(t, "1.0 kB", FormatSize(1000, SizeFormatSI, 1))
+	assert.Equal(t, "1.0 MB", FormatSize(999999, SizeFormatSI, 1))
+	assert.Equal(t, "9.2 EB", FormatSize(math.MaxInt64, SizeFormatSI, 1))
+
+	assert.Equal(t, "1500", FormatSize(1500, SizeFormatBytes, 1))
+}
+
+func TestGetMtime(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "2011-01-02T12:13:14")
+
+	// ACT
+	result, err := getMtime(temp, filePath, info)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "2011-01-02T12:13:14.000000+00:00", result)
+}
+
+func TestGetUnixMtime(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "2011-01-02T12:13:14")
+
+	// ACT
+	result, err := getUnixMtime(temp, filePath, info)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "1293970394", result)
+}
+
+func TestMd5Column(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	column, err := NewContentScanner().HashColumn("md5")
+	require.NoError(t, err)
+
+	// ACT
+	result, err := column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "bb747b3df3130fe1ca4afa93fb7d97c9", result)
+}
+
+func TestMd5Column_empty(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "", "")
+
+	column, err := NewContentScanner().HashColumn("md5")
+	require.NoError(t, err)
+
+	// ACT
+	result, err := column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "d41d8cd98f00b204e9800998ecf8427e", result)
+}
+
+func TestSha1Column(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	column, err := NewContentScanner().HashColumn("sha1")
+	require.NoError(t, err)
+
+	// ACT
+	result, err := column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "93be4612c41d23af1891dac5fd0d535736ffc4e3", result)
+}
+
+func TestSha1Column_empty(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "", "")
+
+	column, err := NewContentScanner().HashColumn("sha1")
+	require.NoError(t, err)
+
+	// ACT
+	result, err := column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "da39a3ee5e6b4b0d3255bfef95601890afd80709", result)
+}
+
+func TestSha256Column(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	column, err := NewContentScanner().HashColumn("sha256")
+	require.NoError(t, err)
+
+	// ACT
+	result, err := column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "e9a92a2ed0d53732ac13b031a27b071814231c8633c9f41844ccba884d482b16", result)
+}
+
+func TestSha256Column_empty(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "", "")
+
+	column, err := NewContentScanner().HashColumn("sha256")
+	require.NoError(t, err)
+
+	// ACT
+	result, err := column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", result)
+}
@@ -0,0 +1,215 @@
+package filist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// バイナリファイルの行数などに表示する値
+const binaryCount = "-"
+
+// countAnalyzer テキストファイルの行数、単語数、文字数を数える
+// 改行はLFとCRLFのどちらも1つとして数えるため、改行コードだけが異なるファイルは同じ値になる
+// バイナリファイルかどうかは、text-binary の列と同じくファイル先頭の内容から判定する
+type countAnalyzer struct {
+	lines int
+	words int
+	chars int
+
+	// 判定のために先頭を溜めておく
+	head    []byte
+	decided bool
+	binary  bool
+	// BOM付きのUTF-16の場合のバイト順 (UTF-8の場合はnil)
+	byteOrder binary.ByteOrder
+	// UTF-16のサロゲートペアの前半
+	surrogate rune
+
+	inWord bool
+	// 直前が改行か (最終行が改行で終わっていない場合も1行と数えるため)
+	lineEnded bool
+	// 直前のCR (CRLFの場合は1文字として数えるため保留)
+	pendingCR bool
+	// 書き込みの区切りで途切れたマルチバイト文字、またはUTF-16の奇数バイト目
+	pending []byte
+}
+
+func newCountAnalyzer() contentAnalyzer {
+	return &countAnalyzer{
+		lineEnded: true,
+	}
+}
+
+func (a *countAnalyzer) Write(p []byte) (int, error) {
+
+	if !a.decided {
+		a.head = append(a.head, p...)
+		if len(a.head) >= headSize {
+			a.decide()
+		}
+		return len(p), nil
+	}
+
+	a.feed(p)
+	return len(p), nil
+}
+
+// decide 先頭の内容から、バイナリファイルかとテキストの文字コードを決める
+func (a *countAnalyzer) decide() {
+
+	head := a.head
+	a.head = nil
+	a.decided = true
+
+	a.binary = isBinary(head[:min(len(head), headSize)])
+
+	switch {
+	case bytes.HasPrefix(head, bomUTF16LE):
+		a.byteOrder = binary.LittleEndian
+		head = head[len(bomUTF16LE):]
+	case bytes.HasPrefix(head, bomUTF16BE):
+		a.byteOrder = binary.BigEndian
+		head = head[len(bomUTF16BE):]
+	}
+
+	a.feed(head)
+}
+
+func (a *countAnalyzer) feed(p []byte) {
+
+	if a.binary {
+		return
+	}
+
+	data := p
+	if len(a.pending) > 0 {
+		data = append(a.pending, p...)
+		a.pending = nil
+	}
+
+	if a.byteOrder != nil {
+		for ; len(data) >= 2; data = data[2:] {
+			a.countUTF16(rune(a.byteOrder.Uint16(data)))
+		}
+	} else {
+		for len(data) > 0 && utf8.FullRune(data) {
+			r, size := utf8.DecodeRune(data)
+			data = data[size:]
+			a.countRune(r)
+		}
+	}
+
+	if len(data) > 0 {
+		// 続きは次の書き込みで
+		a.pending = append([]byte{}, data...)
+	}
+}
+
+// countUTF16 UTF-16の1単位を数える (サロゲートペアは1文字)
+func (a *countAnalyzer) countUTF16(unit rune) {
+
+	if a.surrogate != 0 {
+		high := a.surrogate
+		a.surrogate = 0
+		if r := utf16.DecodeRune(high, unit); r != utf8.RuneError {
+			a.countRune(r)
+			return
+		}
+		// 対になっていない前半は不正な文字
+		a.countRune(utf8.RuneError)
+	}
+
+	switch {
+	case unit >= 0xd800 && unit < 0xdc00:
+		a.surrogate = unit
+	case utf16.IsSurrogate(unit):
+		a.countRune(utf8.RuneError)
+	default:
+		a.countRune(unit)
+	}
+}
+
+func (a *countAnalyzer) countRune(r rune) {
+
+	if a.pendingCR && r != '\n' {
+		a.chars++
+	}
+	a.pendingCR = false
+
+	if r == '\r' {
+		a.pendingCR = true
+	} else {
+		a.chars++
+	}
+
+	if r == '\n' {
+		a.lines++
+	}
+	a.lineEnded = r == '\n'
+
+	if unicode.IsSpace(r) {
+		a.inWord = false
+	} else if !a.inWord {
+		a.inWord = true
+		a.words++
+	}
+}
+
+func (a *countAnalyzer) result() map[string]string {
+
+	if !a.decided {
+		a.decide()
+	}
+
+	if a.binary {
+		return map[string]string{
+			"lines": binaryCount,
+			"words": binaryCount,
+			"chars": binaryCount,
+		}
+	}
+
+	if a.byteOrder != nil {
+		// 末尾で途切れたままの奇数バイト目やサロゲートペアの前半は、不正な文字として数える
+		if a.surrogate != 0 {
+			a.countRune(utf8.RuneError)
+		}
+		if len(a.pending) > 0 {
+			a.countRune(utf8.RuneError)
+		}
+	} else {
+		// 末尾で途切れたままのバイトは、不正な文字として1バイトずつ数える
+		for range a.pending {
+			a.countRune(utf8.RuneError)
+		}
+	}
+	a.pending = nil
+
+	chars := a.chars
+	if a.pendingCR {
+		chars++
+	}
+
+	lines := a.lines
+	if !a.lineEnded {
+		// 改行で終わっていない最終行
+		lines++
+	}
+
+	return map[string]string{
+		"lines": fmt.Sprint(lines),
+		"words": fmt.Sprint(a.words),
+		"chars": fmt.Sprint(chars),
+	}
+}
+
+// CountColumn 行数(lines)、単語数(words)、文字数(chars)の列 (バイナリファイルは "-")
+func (s *ContentScanner) CountColumn(name string) Column {
+
+	s.add("count", newCountAnalyzer)
+	return s.entryColumn(name, ColumnTypeNumber)
+}
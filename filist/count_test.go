@@ -0,0 +1,112 @@
+package filist
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountAnalyzer(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		contents string
+		lines    string
+		words    string
+		chars    string
+	}{
+		{"empty", "", "0", "0", "0"},
+		{"lf", "hello world\nfoo\n", "2", "3", "16"},
+		{"crlf", "hello world\r\nfoo\r\n", "2", "3", "16"},
+		{"no trailing newline", "hello world\nfoo", "2", "3", "15"},
+		{"crlf no trailing newline", "hello world\r\nfoo", "2", "3", "15"},
+		{"only newlines", "\n\n\n", "3", "0", "3"},
+		{"cr only", "a\rb\r", "1", "2", "4"},
+		{"multibyte", "テスト です\n", "1", "2", "7"},
+		{"unicode space", "a　b", "1", "2", "3"},
+		{"invalid utf8", "a\xff\xfeb\n", "1", "1", "5"},
+		{"truncated at end", "a\xe3\x81", "1", "1", "3"},
+		{"binary", "abc\x00def\n", "-", "-", "-"},
+		{"utf16le", "\xff\xfea\x00 \x00b\x00\r\x00\n\x00", "1", "2", "4"},
+		{"utf16be", "\xfe\xff\x00a\x00 \x00b\x00\n", "1", "2", "4"},
+		{"utf16 surrogate pair", "\xff\xfe\x3d\xd8\x00\xde\x0a\x00", "1", "1", "2"},
+		{"utf16 truncated", "\xff\xfea\x00b", "1", "1", "2"},
+		// 先頭にNULが無ければテキスト
+		{"nul after head", strings.Repeat("a", headSize) + "\x00\n", "1", "1", "514"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ARRANGE
+			analyzer := newCountAnalyzer()
+
+			// ACT
+			_, err := analyzer.Write([]byte(tt.contents))
+			require.NoError(t, err)
+			result := analyzer.result()
+
+			// ASSERT
+			assert.Equal(t, tt.lines, result["lines"])
+			assert.Equal(t, tt.words, result["words"])
+			assert.Equal(t, tt.chars, result["chars"])
+		})
+	}
+}
+
+func TestCountAnalyzer_splitWrites(t *testing.T) {
+
+	// ARRANGE
+	contents := []byte("テスト abc\r\nです\r\n")
+	analyzer := newCountAnalyzer()
+
+	// ACT
+	// マルチバイト文字やCRLFの途中で区切って書き込む
+	for i := 0; i < len(contents); i++ {
+		_, err := analyzer.Write(contents[i : i+1])
+		require.NoError(t, err)
+	}
+	result := analyzer.result()
+
+	// ASSERT
+	assert.Equal(t, "2", result["lines"])
+	assert.Equal(t, "3", result["words"])
+	assert.Equal(t, "11", result["chars"])
+}
+
+func TestCountColumn(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "ABC DEF\nG\n", "")
+
+	scanner := NewContentScanner()
+	linesColumn := scanner.CountColumn("lines")
+	sha256Column, err := scanner.HashColumn("sha256")
+	require.NoError(t, err)
+	wordsColumn := scanner.CountColumn("words")
+	charsColumn := scanner.CountColumn("chars")
+
+	// ACT
+	lines, err := linesColumn.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+	require.NoError(t, err)
+
+	// ハッシュと合わせて1回の読み込みで計算済み
+	require.NoError(t, os.Remove(filePath))
+
+	sha256, err := sha256Column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+	require.NoError(t, err)
+	words, err := wordsColumn.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+	require.NoError(t, err)
+	chars, err := charsColumn.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+	require.NoError(t, err)
+
+	// ASSERT
+	assert.Equal(t, "2", lines)
+	assert.Equal(t, "3", words)
+	assert.Equal(t, "10", chars)
+	assert.Equal(t, "fd6d9e88c1fd2e9a276c00daf800dfc54f1e92325089d49cb7650576778d1f52", sha256)
+}
@@ -0,0 +1,202 @@
+package filist
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// encodingAnalyzer 内容から文字コードと改行コードを判定する
+type encodingAnalyzer struct {
+	// BOMの判定のために先頭を溜めておく
+	head     []byte
+	decided  bool
+	encoding string
+
+	ascii   bool
+	invalid bool
+	// 書き込みの区切りで途切れたマルチバイト文字、またはUTF-16の奇数バイト目
+	pending []byte
+
+	pendingCR bool
+	lf        int
+	crlf      int
+	cr        int
+}
+
+func newEncodingAnalyzer() contentAnalyzer {
+	return &encodingAnalyzer{
+		ascii: true,
+	}
+}
+
+func (a *encodingAnalyzer) Write(p []byte) (int, error) {
+
+	if !a.decided {
+		a.head = append(a.head, p...)
+		if len(a.head) < len(bomUTF8) {
+			return len(p), nil
+		}
+		a.decide()
+		return len(p), nil
+	}
+
+	a.feed(p)
+	return len(p), nil
+}
+
+// decide 先頭のBOMから文字コードを決める
+func (a *encodingAnalyzer) decide() {
+
+	head := a.head
+	a.head = nil
+	a.decided = true
+
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		a.encoding = "UTF-8-BOM"
+		head = head[len(bomUTF8):]
+	case bytes.HasPrefix(head, bomUTF16LE):
+		a.encoding = "UTF-16LE"
+		head = head[len(bomUTF16LE):]
+	case bytes.HasPrefix(head, bomUTF16BE):
+		a.encoding = "UTF-16BE"
+		head = head[len(bomUTF16BE):]
+	}
+
+	a.feed(head)
+}
+
+func (a *encodingAnalyzer) feed(p []byte) {
+
+	data := p
+	if len(a.pending) > 0 {
+		data = append(a.pending, p...)
+		a.pending = nil
+	}
+
+	switch a.encoding {
+	case "UTF-16LE", "UTF-16BE":
+		for ; len(data) >= 2; data = data[2:] {
+			unit := uint16(data[0]) | uint16(data[1])<<8
+			if a.encoding == "UTF-16BE" {
+				unit = uint16(data[0])<<8 | uint16(data[1])
+			}
+			if unit < 0x80 {
+				a.countEOL(byte(unit))
+			}
+		}
+
+	default:
+		for len(data) > 0 {
+			if !utf8.FullRune(data) {
+				break
+			}
+
+			r, size := utf8.DecodeRune(data)
+			if r == utf8.RuneError && size == 1 {
+				a.invalid = true
+			}
+			if size > 1 || r >= utf8.RuneSelf {
+				a.ascii = false
+			}
+
+			// CRとLFはマルチバイト文字の途中には現れない
+			a.countEOL(data[0])
+			data = data[size:]
+		}
+	}
+
+	if len(data) > 0 {
+		// 続きは次の書き込みで
+		a.pending = append([]byte{}, data...)
+	}
+}
+
+func (a *encodingAnalyzer) countEOL(c byte) {
+
+	if a.pendingCR {
+		a.pendingCR = false
+		if c == '\n' {
+			a.crlf++
+			return
+		}
+		a.cr++
+	}
+
+	switch c {
+	case '\r':
+		a.pendingCR = true
+	case '\n':
+		a.lf++
+	}
+}
+
+func (a *encodingAnalyzer) result() map[string]string {
+
+	if !a.decided {
+		a.decide()
+	}
+
+	if len(a.pending) > 0 && a.encoding != "UTF-16LE" && a.encoding != "UTF-16BE" {
+		// 末尾で途切れたままのマルチバイト文字
+		a.invalid = true
+		a.ascii = false
+	}
+
+	if a.pendingCR {
+		a.cr++
+	}
+
+	encoding := a.encoding
+	if encoding == "" {
+		switch {
+		case a.invalid:
+			encoding = "invalid-UTF-8"
+		case a.ascii:
+			encoding = "ASCII"
+		default:
+			encoding = "UTF-8"
+		}
+	}
+
+	return map[string]string{
+		"encoding": encoding,
+		"eol":      a.eol(),
+	}
+}
+
+func (a *encodingAnalyzer) eol() string {
+
+	var kinds []string
+	if a.lf > 0 {
+		kinds = append(kinds, "LF")
+	}
+	if a.crlf > 0 {
+		kinds = append(kinds, "CRLF")
+	}
+	if a.cr > 0 {
+		kinds = append(kinds, "CR")
+	}
+
+	switch len(kinds) {
+	case 0:
+		return "none"
+	case 1:
+		return kinds[0]
+	default:
+		return "mixed"
+	}
+}
+
+// EncodingColumn 文字コード(encoding)、改行コード(eol)の列
+func (s *ContentScanner) EncodingColumn(name string) Column {
+
+	s.add("encoding", newEncodingAnalyzer)
+	return s.entryColumn(name, ColumnTypeString)
+}
@@ -0,0 +1,86 @@
+package filist
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodingAnalyzer(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		contents string
+		encoding string
+		eol      string
+	}{
+		{"empty", "", "ASCII", "none"},
+		{"ascii lf", "abc\ndef\n", "ASCII", "LF"},
+		{"ascii crlf", "abc\r\ndef\r\n", "ASCII", "CRLF"},
+		{"ascii cr", "abc\rdef\r", "ASCII", "CR"},
+		{"ascii mixed", "abc\r\ndef\nghi", "ASCII", "mixed"},
+		{"ascii no eol", "abc", "ASCII", "none"},
+		{"utf8", "テスト\n", "UTF-8", "LF"},
+		{"utf8 bom", "\xef\xbb\xbfテスト\r\n", "UTF-8-BOM", "CRLF"},
+		{"utf8 bom only", "\xef\xbb\xbf", "UTF-8-BOM", "none"},
+		{"utf16le", "\xff\xfeA\x00\r\x00\n\x00B\x00", "UTF-16LE", "CRLF"},
+		{"utf16be", "\xfe\xff\x00A\x00\n\x00B\x00\n", "UTF-16BE", "LF"},
+		{"utf16le bom only", "\xff\xfe", "UTF-16LE", "none"},
+		{"invalid", "abc\xff\n", "invalid-UTF-8", "LF"},
+		{"truncated", "abc\xe3\x83", "invalid-UTF-8", "none"},
+		{"short", "a\n", "ASCII", "LF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ARRANGE
+			analyzer := newEncodingAnalyzer()
+
+			// ACT
+			_, err := analyzer.Write([]byte(tt.contents))
+			require.NoError(t, err)
+			result := analyzer.result()
+
+			// ASSERT
+			assert.Equal(t, tt.encoding, result["encoding"])
+			assert.Equal(t, tt.eol, result["eol"])
+		})
+	}
+}
+
+func TestEncodingAnalyzer_splitWrites(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		contents string
+		encoding string
+		eol      string
+	}{
+		{"utf8 bom", "\xef\xbb\xbfテスト\r\nabc\r\n", "UTF-8-BOM", "CRLF"},
+		{"utf8", "テスト\r\nabc\r\n", "UTF-8", "CRLF"},
+		{"utf16le", "\xff\xfe\x42\x30\r\x00\n\x00", "UTF-16LE", "CRLF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ARRANGE
+			analyzer := newEncodingAnalyzer()
+			contents := []byte(tt.contents)
+
+			// ACT
+			// BOMやマルチバイト文字、CRLFの途中で区切って書き込む
+			for i := 0; i < len(contents); i++ {
+				_, err := analyzer.Write(contents[i : i+1])
+				require.NoError(t, err)
+			}
+			result := analyzer.result()
+
+			// ASSERT
+			assert.Equal(t, tt.encoding, result["encoding"])
+			assert.Equal(t, tt.eol, result["eol"])
+		})
+	}
+}
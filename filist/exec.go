@@ -0,0 +1,342 @@
+package filist
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExecRunner 外部コマンドの出力を値とする列を、ファイルごとにまとめて実行する
+// 同時実行数の上限までは、同じファイルに対する複数のコマンドや、後続のファイルのコマンドも並行して実行する
+type ExecRunner struct {
+	timeout  time.Duration
+	sem      chan struct{}
+	commands []execCommand
+
+	mu       sync.Mutex
+	filePath string
+	values   map[string]string
+	// 先行して実行を始めたファイルの結果
+	pending map[string]*execResult
+}
+
+type execResult struct {
+	done   chan struct{}
+	values map[string]string
+	err    error
+}
+
+type execCommand struct {
+	name string
+	args []string
+}
+
+// NewExecRunner jobs は同時に実行するコマンド数の上限、timeout はコマンドごとのタイムアウト (0は無制限)
+func NewExecRunner(jobs int, timeout time.Duration) *ExecRunner {
+
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	return &ExecRunner{
+		timeout: timeout,
+		sem:     make(chan struct{}, jobs),
+		pending: map[string]*execResult{},
+	}
+}
+
+// Column 外部コマンドの標準出力(前後の空白を除く)を値とする列
+// コマンドの引数中の {} はファイルのパスに置き換える
+func (r *ExecRunner) Column(name string, command string) (Column, error) {
+
+	args, err := splitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("command is empty: %s", name)
+	}
+
+	if !r.hasCommand(name) {
+		// 同じ列を複数回指定しても、コマンドはファイルごとに1回だけ実行する
+		r.commands = append(r.commands, execCommand{name: name, args: args})
+	}
+
+	return NewColumn(name, ColumnTypeString, func(entry *Entry) (string, error) {
+
+		// OS上に無いもの(アーカイブ内のエントリ)は、外部コマンドに渡せない
+		if entry.Info.IsDir() || !onOSFileSystem(entry.Info) {
+			return "", nil
+		}
+
+		values, err := r.run(entry.Context(), entry.Path)
+		if err != nil {
+			return "", err
+		}
+
+		return values[name], nil
+	}), nil
+}
+
+// Register 外部コマンドの列を、他の列と同じく名前で指定できるように登録する
+// 既に登録されている列 (組み込みの列など) と同じ名前は、その列を置き換えないようにエラーとする
+func (r *ExecRunner) Register(registry *Registry, name string, command string) error {
+
+	if _, ok := registry.Lookup(name); ok {
+		return fmt.Errorf("column already exists: %s", name)
+	}
+
+	args, err := splitCommand(command)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("command is empty: %s", name)
+	}
+
+	registry.Register(ColumnDefinition{
+		Name:        name,
+		Description: "Output of command: " + strings.Join(args, " "),
+		New: func(config *ColumnConfig, option string) (Column, error) {
+			return r.Column(name, command)
+		},
+	})
+
+	return nil
+}
+
+func (r *ExecRunner) hasCommand(name string) bool {
+
+	for _, command := range r.commands {
+		if command.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Lookahead 後続のファイルのコマンドも並行して実行できるように、fn の呼び出しを最大 jobs エントリ分遅らせる
+// fn は走査の順に呼び出す。走査が終わったら、返却した flush で残りのエントリの fn を呼び出す
+func (r *ExecRunner) Lookahead(fn WalkFunc) (WalkFunc, func() error) {
+
+	if len(r.commands) == 0 {
+		return fn, func() error { return nil }
+	}
+
+	var queue []*Entry
+
+	flush := func() error {
+		for len(queue) != 0 {
+			entry := queue[0]
+			queue = queue[1:]
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return func(entry *Entry) error {
+
+		if entry.Info.IsDir() || !onOSFileSystem(entry.Info) {
+			// コマンドを実行しないもの (アーカイブ内のエントリは、アーカイブが閉じられる前に処理する)
+			if err := flush(); err != nil {
+				return err
+			}
+			return fn(entry)
+		}
+
+		r.start(entry.Context(), entry.Path)
+		queue = append(queue, entry)
+
+		if len(queue) > cap(r.sem) {
+			entry := queue[0]
+			queue = queue[1:]
+			return fn(entry)
+		}
+		return nil
+	}, flush
+}
+
+// start コマンドの実行を先行して始める
+func (r *ExecRunner) start(ctx context.Context, filePath string) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.pending[filePath]; ok {
+		return
+	}
+
+	result := &execResult{done: make(chan struct{})}
+	r.pending[filePath] = result
+
+	go func() {
+		defer close(result.done)
+		result.values, result.err = r.execute(ctx, filePath)
+	}()
+}
+
+func (r *ExecRunner) run(ctx context.Context, filePath string) (map[string]string, error) {
+
+	r.mu.Lock()
+	if r.values != nil && r.filePath == filePath {
+		// 同じファイルの別の列から呼ばれた場合は実行済みの値を返す
+		values := r.values
+		r.mu.Unlock()
+		return values, nil
+	}
+
+	result, ok := r.pending[filePath]
+	delete(r.pending, filePath)
+	r.mu.Unlock()
+
+	var values map[string]string
+	var err error
+	if ok {
+		<-result.done
+		values, err = result.values, result.err
+	} else {
+		values, err = r.execute(ctx, filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.filePath = filePath
+	r.values = values
+	r.mu.Unlock()
+
+	return values, nil
+}
+
+// execute ファイルに対する全てのコマンドを、同時実行数の上限まで並行して実行する
+func (r *ExecRunner) execute(ctx context.Context, filePath string) (map[string]string, error) {
+
+	results := make([]string, len(r.commands))
+	errs := make([]error, len(r.commands))
+
+	var wg sync.WaitGroup
+	for i, command := range r.commands {
+		r.sem <- struct{}{}
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-r.sem }()
+
+			results[i], errs[i] = r.exec(ctx, command, filePath)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for i, command := range r.commands {
+		values[command.name] = results[i]
+	}
+
+	return values, nil
+}
+
+func (r *ExecRunner) exec(parent context.Context, command execCommand, filePath string) (string, error) {
+
+	ctx := parent
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	args := make([]string, len(command.args))
+	for i, arg := range command.args {
+		args[i] = strings.ReplaceAll(arg, "{}", filePath)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if err := parent.Err(); err != nil {
+			// 走査自体が中断された
+			return "", err
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%s: command timed out after %s: %s", command.name, r.timeout, filePath)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%s: %w: %s: %s", command.name, err, filePath, message)
+		}
+		return "", fmt.Errorf("%s: %w: %s", command.name, err, filePath)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// onOSFileSystem OSのファイルシステム上のエントリか
+func onOSFileSystem(info os.FileInfo) bool {
+
+	fsInfo, ok := info.(*fsFileInfo)
+	if !ok {
+		return true
+	}
+
+	_, ok = fsInfo.fsys.(*osFS)
+	return ok && !fsInfo.archive
+}
+
+// splitCommand コマンドを空白で引数に分割する (シングルクォート、ダブルクォートで囲んだ部分は分割しない)
+func splitCommand(command string) ([]string, error) {
+
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+
+	for _, r := range command {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote: %s", command)
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
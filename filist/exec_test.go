@@ -0,0 +1,334 @@
+package filist
+
+import (
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecRunner_Column(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on this platform")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "a.txt", "hello\n", "")
+
+	runner := NewExecRunner(2, 0)
+
+	catColumn, err := runner.Column("cat", "cat {}")
+	require.NoError(t, err)
+	nameColumn, err := runner.Column("name", `sh -c 'basename "$1"' sh {}`)
+	require.NoError(t, err)
+
+	entry := &Entry{Path: filePath, BaseDir: temp, Info: info}
+
+	// ACT
+	values, err := Values(entry, []Column{catColumn, nameColumn})
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hello", "a.txt"}, values)
+}
+
+func TestExecRunner_Column_Dir(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFile(t, filepath.Join(temp, "a"), "a.txt", "A", "")
+
+	runner := NewExecRunner(1, 0)
+	column, err := runner.Column("x", "command-not-found {}")
+	require.NoError(t, err)
+
+	walker := &Walker{IncludeDirectories: true, ExcludeFiles: true}
+
+	// ACT
+	result := walkAndFormat(t, []Column{RelPathColumn(), column}, func(fn WalkFunc) error {
+		return walker.Walk(t.Context(), []string{temp}, fn)
+	})
+
+	// ASSERT
+	assert.Equal(t, allLines(line("a"+string(filepath.Separator), "")), result)
+}
+
+func TestExecRunner_Column_NotOnOSFileSystem(t *testing.T) {
+
+	// ARRANGE
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("A")},
+	}
+
+	runner := NewExecRunner(1, 0)
+	column, err := runner.Column("x", "command-not-found {}")
+	require.NoError(t, err)
+
+	walker := &Walker{}
+
+	// ACT
+	result := walkAndFormat(t, []Column{RelPathColumn(), column}, func(fn WalkFunc) error {
+		return walker.WalkFS(t.Context(), fsys, filepath.Join(string(filepath.Separator), "virtual"), fn)
+	})
+
+	// ASSERT
+	assert.Equal(t, allLines(line("a.txt", "")), result)
+}
+
+func TestExecRunner_Column_Failed(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on this platform")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "a.txt", "A", "")
+
+	runner := NewExecRunner(1, 0)
+	column, err := runner.Column("x", `sh -c 'echo failed >&2; exit 3'`)
+	require.NoError(t, err)
+
+	// ACT
+	_, err = column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+
+	// ASSERT
+	assert.EqualError(t, err, "x: exit status 3: "+filePath+": failed")
+}
+
+func TestExecRunner_Column_Timeout(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep is not available on this platform")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "a.txt", "A", "")
+
+	runner := NewExecRunner(1, 100*time.Millisecond)
+	column, err := runner.Column("x", "sleep 10")
+	require.NoError(t, err)
+
+	// ACT
+	_, err = column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+
+	// ASSERT
+	assert.EqualError(t, err, "x: command timed out after 100ms: "+filePath)
+}
+
+func TestExecRunner_Column_Empty(t *testing.T) {
+
+	// ARRANGE
+	runner := NewExecRunner(1, 0)
+
+	// ACT
+	_, err := runner.Column("x", "  ")
+
+	// ASSERT
+	assert.EqualError(t, err, "command is empty: x")
+}
+
+func TestExecRunner_Lookahead(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on this platform")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		setupFile(t, temp, name, "", "")
+	}
+
+	// 実行中のコマンドは running に印のファイルを置き、他のコマンドと重なるのを待ってから (最大2秒)、実行中の数を出力する
+	running := t.TempDir()
+	command := `sh -c '` +
+		`m="$2/$(basename "$1")"; touch "$m"; i=0; ` +
+		`while [ $(ls "$2" | wc -l) -lt 2 ] && [ $i -lt 20 ]; do sleep 0.1; i=$((i+1)); done; ` +
+		`ls "$2" | wc -l; sleep 0.1; rm "$m"` +
+		`' sh {} '` + running + `'`
+
+	runner := NewExecRunner(2, 0)
+	column, err := runner.Column("running", command)
+	require.NoError(t, err)
+
+	walker := &Walker{}
+
+	// ACT
+	result := walkAndFormat(t, []Column{RelPathColumn(), column}, func(fn WalkFunc) error {
+		walkFn, flush := runner.Lookahead(fn)
+		if err := walker.Walk(t.Context(), []string{temp}, walkFn); err != nil {
+			return err
+		}
+		return flush()
+	})
+
+	// ASSERT
+	// 出力は走査の順
+	lines := strings.Split(strings.TrimSuffix(result, "\n"), "\n")
+	require.Len(t, lines, 4)
+
+	maxRunning := 0
+	for i, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		rel, value, _ := strings.Cut(lines[i], "\t")
+		assert.Equal(t, name, rel)
+
+		count, err := strconv.Atoi(value)
+		require.NoError(t, err)
+		// 同時に実行するのは jobs まで
+		assert.LessOrEqual(t, count, 2)
+		maxRunning = max(maxRunning, count)
+	}
+
+	// 列は1つでも、別のファイルのコマンドが並行して実行される
+	assert.Equal(t, 2, maxRunning)
+}
+
+func TestExecRunner_Lookahead_NoCommands(t *testing.T) {
+
+	// ARRANGE
+	runner := NewExecRunner(2, 0)
+
+	var called []string
+	fn := func(entry *Entry) error {
+		called = append(called, entry.Path)
+		return nil
+	}
+
+	// ACT
+	walkFn, flush := runner.Lookahead(fn)
+	require.NoError(t, walkFn(&Entry{Path: "a"}))
+
+	// ASSERT
+	// コマンドが無ければ遅らせない
+	assert.Equal(t, []string{"a"}, called)
+	require.NoError(t, flush())
+	assert.Equal(t, []string{"a"}, called)
+}
+
+func TestExecRunner_Register(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on this platform")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "a.txt", "hello\n", "")
+
+	runner := NewExecRunner(1, 0)
+	registry := NewRegistry()
+
+	// ACT
+	err := runner.Register(registry, "cat", "cat {}")
+
+	// ASSERT
+	require.NoError(t, err)
+
+	definition, ok := registry.Lookup("cat")
+	require.True(t, ok)
+	assert.Equal(t, "Output of command: cat {}", definition.Description)
+
+	// 同じ列を複数回指定しても、コマンドは1回だけ
+	columns, err := registry.Parse("rel,cat,cat", &ColumnConfig{})
+	require.NoError(t, err)
+	assert.Len(t, runner.commands, 1)
+
+	values, err := Values(&Entry{Path: filePath, BaseDir: temp, Info: info}, columns)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "hello", "hello"}, values)
+}
+
+func TestExecRunner_Register_Invalid(t *testing.T) {
+
+	// ARRANGE
+	runner := NewExecRunner(1, 0)
+	registry := NewRegistry()
+
+	// ACT
+	err := runner.Register(registry, "x", "'unterminated")
+
+	// ASSERT
+	require.EqualError(t, err, "unterminated quote: 'unterminated")
+
+	_, ok := registry.Lookup("x")
+	assert.False(t, ok)
+}
+
+func TestExecRunner_Register_Duplicate(t *testing.T) {
+
+	tests := []struct {
+		name string
+	}{
+		{"rel"},
+		{"sha256"},
+		{"x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ARRANGE
+			runner := NewExecRunner(1, 0)
+			registry := NewRegistry()
+			require.NoError(t, runner.Register(registry, "x", "echo x"))
+
+			// ACT
+			err := runner.Register(registry, tt.name, "echo fake")
+
+			// ASSERT
+			require.EqualError(t, err, "column already exists: "+tt.name)
+
+			// 既存の列は置き換えない
+			definition, ok := registry.Lookup(tt.name)
+			require.True(t, ok)
+			assert.NotEqual(t, "Output of command: echo fake", definition.Description)
+		})
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		command  string
+		expected []string
+	}{
+		{"simple", "wc -l {}", []string{"wc", "-l", "{}"}},
+		{"spaces", "  wc \t -l  ", []string{"wc", "-l"}},
+		{"single quote", `sh -c 'echo "$1"' sh {}`, []string{"sh", "-c", `echo "$1"`, "sh", "{}"}},
+		{"double quote", `echo "a b"c`, []string{"echo", "a bc"}},
+		{"empty quote", `echo ''`, []string{"echo", ""}},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ACT
+			result, err := splitCommand(tt.command)
+
+			// ASSERT
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestSplitCommand_UnterminatedQuote(t *testing.T) {
+
+	// ACT
+	_, err := splitCommand(`echo 'abc`)
+
+	// ASSERT
+	assert.EqualError(t, err, "unterminated quote: echo 'abc")
+}
@@ -0,0 +1,73 @@
+package filist
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+
+	loc, _ := time.LoadLocation("UTC")
+	time.Local = loc
+
+	code := m.Run()
+	os.Exit(code)
+}
+
+func setupDir(t *testing.T, dir string) {
+
+	err := os.MkdirAll(dir, 0777)
+	require.NoError(t, err)
+}
+
+func setupFile(t *testing.T, dir string, name string, contents string, mtimeStr string) (string, fs.FileInfo) {
+
+	setupDir(t, dir)
+
+	filePath := filepath.Join(dir, name)
+
+	file, err := os.Create(filePath)
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, err = file.Write([]byte(contents))
+	require.NoError(t, err)
+
+	mtime := time.Now()
+	if mtimeStr != "" {
+		mtime, err = time.Parse(time.RFC3339, mtimeStr+"Z")
+		require.NoError(t, err)
+	}
+
+	err = os.Chtimes(filePath, mtime, mtime)
+	require.NoError(t, err)
+
+	stat, err := file.Stat()
+	require.NoError(t, err)
+
+	return filePath, stat
+}
+
+func setupFiles(t *testing.T, root string) {
+
+	setupFile(t, root, "1.txt", strings.Repeat("x", 0), "2020-01-01T00:00:00")
+	setupFile(t, filepath.Join(root, "a"), "a.txt", strings.Repeat("x", 1), "2020-12-21T11:12:21")
+	setupFile(t, filepath.Join(root, "a"), "b.txt", strings.Repeat("x", 10), "2020-12-20T00:00:00")
+	setupFile(t, filepath.Join(root, "a/xxx"), "x.txt", strings.Repeat("x", 20), "2019-01-01T12:34:56")
+	setupDir(t, filepath.Join(root, "a/xxx/yyy"))
+	setupDir(t, filepath.Join(root, "a/xxx/zzz"))
+	setupFile(t, filepath.Join(root, "x/y/z"), "テスト.txt", strings.Repeat("x", 100), "2021-03-28T00:12:34")
+}
+
+func line(items ...string) string {
+	return strings.Join(items, "\t") + "\n"
+}
+func allLines(lines ...string) string {
+	return strings.Join(lines, "")
+}
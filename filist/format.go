@@ -0,0 +1,82 @@
+package filist
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Formatter エントリごとの列の値を出力する
+type Formatter interface {
+	Format(out io.Writer, columns []Column, values []string) error
+}
+
+// TSVFormatter タブ区切りで1エントリ1行として出力する
+type TSVFormatter struct {
+	// 改行の代わりにNULで終端する
+	NullTerminated bool
+	// 制御文字をエスケープする
+	Escape bool
+}
+
+func (f *TSVFormatter) Format(out io.Writer, columns []Column, values []string) error {
+
+	var b strings.Builder
+
+	for i, value := range values {
+		if i > 0 {
+			b.WriteString("\t")
+		}
+		if f.Escape {
+			value = escapeControl(value)
+		}
+		b.WriteString(value)
+	}
+
+	if f.NullTerminated {
+		b.WriteString("\x00")
+	} else {
+		b.WriteString("\n")
+	}
+
+	// 1行分をまとめて書き込む
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// escapeControl 制御文字をエスケープして人が読める形にする
+// 元の文字列と区別できるように、バックスラッシュ自体もエスケープする
+func escapeControl(value string) string {
+
+	var b strings.Builder
+
+	for i := 0; i < len(value); {
+		r, size := utf8.DecodeRuneInString(value[i:])
+
+		switch {
+		case r == utf8.RuneError && size == 1:
+			// 不正なUTF-8のバイト
+			fmt.Fprintf(&b, "\\x%02x", value[i])
+		case r == '\\':
+			b.WriteString("\\\\")
+		case r == '\n':
+			b.WriteString("\\n")
+		case r == '\r':
+			b.WriteString("\\r")
+		case r == '\t':
+			b.WriteString("\\t")
+		case r < 0x80 && unicode.IsControl(r):
+			fmt.Fprintf(&b, "\\x%02x", r)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&b, "\\u%04x", r)
+		default:
+			b.WriteRune(r)
+		}
+
+		i += size
+	}
+
+	return b.String()
+}
@@ -0,0 +1,18 @@
+package filist
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeControl(t *testing.T) {
+
+	assert.Equal(t, "abc.txt", escapeControl("abc.txt"))
+	assert.Equal(t, "テスト.txt", escapeControl("テスト.txt"))
+	assert.Equal(t, `a\nb\rc\td`, escapeControl("a\nb\rc\td"))
+	assert.Equal(t, `a\\n`, escapeControl(`a\n`))
+	assert.Equal(t, `\x00\x1b\x7f`, escapeControl("\x00\x1b\x7f"))
+	assert.Equal(t, `\u0085`, escapeControl("\u0085"))
+	assert.Equal(t, `a\xffb`, escapeControl("a\xffb"))
+}
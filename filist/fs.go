@@ -0,0 +1,200 @@
+package filist
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// osFS OSのファイルシステム
+// os.DirFS と異なり、エラーにはOS上のパスがそのまま含まれ、シンボリックリンクのリンク先も取得できる
+type osFS struct {
+	dir string
+}
+
+func newOSFS(dir string) *osFS {
+	return &osFS{dir: dir}
+}
+
+func (f *osFS) join(op string, name string) (string, error) {
+
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	return filepath.Join(f.dir, filepath.FromSlash(name)), nil
+}
+
+func (f *osFS) Open(name string) (fs.File, error) {
+
+	fullPath, err := f.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(fullPath)
+}
+
+func (f *osFS) Stat(name string) (fs.FileInfo, error) {
+
+	fullPath, err := f.join("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Stat(fullPath)
+}
+
+func (f *osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+
+	fullPath, err := f.join("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadDir(fullPath)
+}
+
+func (f *osFS) ReadLink(name string) (string, error) {
+
+	fullPath, err := f.join("readlink", name)
+	if err != nil {
+		return "", err
+	}
+
+	return os.Readlink(fullPath)
+}
+
+// readLinkFS シンボリックリンクのリンク先を取得できるファイルシステム
+type readLinkFS interface {
+	fs.FS
+	ReadLink(name string) (string, error)
+}
+
+// fsFileInfo fs.FS 上のエントリの情報
+// 内容の読み込み先と、配下の一覧の取得先を合わせて持つ
+type fsFileInfo struct {
+	fs.FileInfo
+	// 内容の読み込み (アーカイブ自体の場合は、アーカイブファイルの読み込み)
+	open func() (io.ReadCloser, error)
+	// 配下の一覧の取得先
+	fsys fs.FS
+	name string
+	// アーカイブ自体をディレクトリとして扱う場合
+	archive bool
+	// アーカイブ内のエントリ
+	inArchive bool
+}
+
+func (i *fsFileInfo) IsDir() bool {
+	return i.archive || i.FileInfo.IsDir()
+}
+
+func (i *fsFileInfo) Mode() fs.FileMode {
+
+	if i.archive {
+		return i.FileInfo.Mode() | fs.ModeDir
+	}
+	return i.FileInfo.Mode()
+}
+
+func newFSFileInfo(fsys fs.FS, name string, info fs.FileInfo) *fsFileInfo {
+	return &fsFileInfo{
+		FileInfo: info,
+		open: func() (io.ReadCloser, error) {
+			return fsys.Open(name)
+		},
+		fsys: fsys,
+		name: name,
+	}
+}
+
+// fsDirEntry fs.FS 上のエントリ (Info で内容の読み込み先を持った情報を返す)
+type fsDirEntry struct {
+	fs.DirEntry
+	fsys      fs.FS
+	name      string
+	inArchive bool
+}
+
+func (e *fsDirEntry) Info() (fs.FileInfo, error) {
+
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+
+	fsInfo := newFSFileInfo(e.fsys, e.name, info)
+	fsInfo.inArchive = e.inArchive
+	return fsInfo, nil
+}
+
+// openFile ファイルの内容を開く (fs.FS 上のエントリは、その fs.FS から読み込む)
+func openFile(filePath string, info os.FileInfo) (io.ReadCloser, error) {
+
+	if fsInfo, ok := info.(*fsFileInfo); ok {
+		return fsInfo.open()
+	}
+
+	return os.Open(filePath)
+}
+
+// readDir ディレクトリ配下の一覧を取得する (fs.FS 上のディレクトリは、その fs.FS から取得する)
+func readDir(dirPath string, info os.FileInfo) ([]fs.DirEntry, error) {
+
+	fsInfo, ok := info.(*fsFileInfo)
+	if !ok {
+		return os.ReadDir(dirPath)
+	}
+
+	entries, err := fs.ReadDir(fsInfo.fsys, fsInfo.name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, entry := range entries {
+		entries[i] = &fsDirEntry{
+			DirEntry:  entry,
+			fsys:      fsInfo.fsys,
+			name:      path.Join(fsInfo.name, entry.Name()),
+			inArchive: fsInfo.archive || fsInfo.inArchive,
+		}
+	}
+
+	return entries, nil
+}
+
+// readLink シンボリックリンクのリンク先を取得する
+// リンク先を取得できない fs.FS (zipなど) では、内容をリンク先とする
+func readLink(filePath string, info os.FileInfo) (string, error) {
+
+	fsInfo, ok := info.(*fsFileInfo)
+	if !ok {
+		return os.Readlink(filePath)
+	}
+
+	if fsys, ok := fsInfo.fsys.(readLinkFS); ok {
+		return fsys.ReadLink(fsInfo.name)
+	}
+
+	f, err := openFile(filePath, info)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	target, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+
+	return string(target), nil
+}
+
+func isArchiveRoot(info os.FileInfo) bool {
+
+	fsInfo, ok := info.(*fsFileInfo)
+	return ok && fsInfo.archive
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package filist
+
+import (
+	"os"
+)
+
+// DeviceIDSupported デバイスIDが取得できるプラットフォームか
+const DeviceIDSupported = false
+
+// deviceID ファイルが存在するデバイスのIDを返す
+func deviceID(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package filist
+
+import (
+	"os"
+	"syscall"
+)
+
+// DeviceIDSupported デバイスIDが取得できるプラットフォームか
+const DeviceIDSupported = true
+
+// deviceID ファイルが存在するデバイスのIDを返す
+func deviceID(info os.FileInfo) (uint64, bool) {
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+
+	return uint64(stat.Dev), true
+}
@@ -0,0 +1,60 @@
+//go:build unix
+
+package filist
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentColumns_Fifo(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath := filepath.Join(temp, "p")
+	require.NoError(t, syscall.Mkfifo(filePath, 0644))
+
+	info, err := os.Lstat(filePath)
+	require.NoError(t, err)
+
+	hash, err := NewContentScanner().HashColumn("md5")
+	require.NoError(t, err)
+
+	columns := []Column{hash, MimeColumn(), TextBinaryColumn()}
+
+	// ACT
+	// 書き込み側の無いパイプを開くと終わらないため、内容は読まない
+	values, err := Values(&Entry{Path: filePath, BaseDir: temp, Info: info}, columns)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "", ""}, values)
+}
+
+func TestContentColumns_Symlink(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFile(t, temp, "a.txt", "ABCDEFG", "")
+	filePath := filepath.Join(temp, "link")
+	require.NoError(t, os.Symlink("a.txt", filePath))
+
+	info, err := os.Lstat(filePath)
+	require.NoError(t, err)
+
+	hash, err := NewContentScanner().HashColumn("md5")
+	require.NoError(t, err)
+
+	// ACT
+	result, err := hash.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+
+	// ASSERT
+	// シンボリックリンクはリンク先の内容
+	require.NoError(t, err)
+	assert.Equal(t, "bb747b3df3130fe1ca4afa93fb7d97c9", result)
+}
@@ -0,0 +1,142 @@
+package filist
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"hash/crc32"
+	"hash/crc64"
+	"hash/fnv"
+	"strings"
+)
+
+// hashAlgorithm ハッシュのアルゴリズム
+type hashAlgorithm struct {
+	name string
+	new  func() hash.Hash
+	// 暗号学的ハッシュか (HMACに使えるのはこちらのみ)
+	cryptographic bool
+}
+
+var hashAlgorithms = []hashAlgorithm{
+	{"md5", md5.New, true},
+	{"sha1", sha1.New, true},
+	{"sha224", sha256.New224, true},
+	{"sha256", sha256.New, true},
+	{"sha384", sha512.New384, true},
+	{"sha512", sha512.New, true},
+	{"sha512-224", sha512.New512_224, true},
+	{"sha512-256", sha512.New512_256, true},
+	{"sha3-224", func() hash.Hash { return sha3.New224() }, true},
+	{"sha3-256", func() hash.Hash { return sha3.New256() }, true},
+	{"sha3-384", func() hash.Hash { return sha3.New384() }, true},
+	{"sha3-512", func() hash.Hash { return sha3.New512() }, true},
+	{"crc32", func() hash.Hash { return crc32.NewIEEE() }, false},
+	{"crc32c", func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) }, false},
+	{"crc64-iso", func() hash.Hash { return crc64.New(crc64.MakeTable(crc64.ISO)) }, false},
+	{"crc64-ecma", func() hash.Hash { return crc64.New(crc64.MakeTable(crc64.ECMA)) }, false},
+	{"adler32", func() hash.Hash { return adler32.New() }, false},
+	{"fnv32", func() hash.Hash { return fnv.New32() }, false},
+	{"fnv32a", func() hash.Hash { return fnv.New32a() }, false},
+	{"fnv64", func() hash.Hash { return fnv.New64() }, false},
+	{"fnv64a", func() hash.Hash { return fnv.New64a() }, false},
+	{"fnv128", fnv.New128, false},
+	{"fnv128a", fnv.New128a, false},
+}
+
+// HashAlgorithmNames 対応しているアルゴリズムの名前を返す
+// cryptographicOnly の場合は、HMACに使える暗号学的ハッシュのみ
+func HashAlgorithmNames(cryptographicOnly bool) []string {
+
+	var names []string
+	for _, algorithm := range hashAlgorithms {
+		if cryptographicOnly && !algorithm.cryptographic {
+			continue
+		}
+		names = append(names, algorithm.name)
+	}
+
+	return names
+}
+
+func normalizeHashName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func findHashAlgorithm(name string) (hashAlgorithm, error) {
+
+	name = normalizeHashName(name)
+	for _, algorithm := range hashAlgorithms {
+		if algorithm.name == name {
+			return algorithm, nil
+		}
+	}
+
+	return hashAlgorithm{}, fmt.Errorf("unknown hash algorithm: %s", name)
+}
+
+// HashColumn 指定のアルゴリズムのハッシュの列
+// 他の内容から計算する列と合わせて、ファイルの1回の読み込みでまとめて計算する
+func (s *ContentScanner) HashColumn(name string) (Column, error) {
+
+	algorithm, err := findHashAlgorithm(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.addHashColumn(algorithm), nil
+}
+
+// HMACColumn 指定のアルゴリズムと鍵によるHMACの列
+// 通常のハッシュと同じく、1回の読み込みでまとめて計算する
+func (s *ContentScanner) HMACColumn(name string, key []byte) (Column, error) {
+
+	algorithm, err := findHashAlgorithm(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !algorithm.cryptographic {
+		return nil, fmt.Errorf("HMAC is not supported for %s", algorithm.name)
+	}
+
+	return s.addHashColumn(hashAlgorithm{
+		name: "hmac-" + algorithm.name,
+		new: func() hash.Hash {
+			return hmac.New(algorithm.new, key)
+		},
+		cryptographic: true,
+	}), nil
+}
+
+func (s *ContentScanner) addHashColumn(algorithm hashAlgorithm) Column {
+
+	s.add(algorithm.name, func() contentAnalyzer {
+		return &hashAnalyzer{name: algorithm.name, hash: algorithm.new()}
+	})
+
+	return s.entryColumn(algorithm.name, ColumnTypeString)
+}
+
+// hashAnalyzer 内容からハッシュを計算する
+type hashAnalyzer struct {
+	name string
+	hash hash.Hash
+}
+
+func (a *hashAnalyzer) Write(p []byte) (int, error) {
+	return a.hash.Write(p)
+}
+
+func (a *hashAnalyzer) result() map[string]string {
+	return map[string]string{
+		a.name: hex.EncodeToString(a.hash.Sum(nil)),
+	}
+}
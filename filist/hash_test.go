@@ -0,0 +1,156 @@
+package filist
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashColumn(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	scanner := NewContentScanner()
+	sha512Column, err := scanner.HashColumn("sha512")
+	require.NoError(t, err)
+	sha3Column, err := scanner.HashColumn("SHA3-256")
+	require.NoError(t, err)
+	crc32Column, err := scanner.HashColumn("crc32")
+	require.NoError(t, err)
+
+	// ACT
+	sha512Result, err := sha512Column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+	require.NoError(t, err)
+	sha3Result, err := sha3Column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+	require.NoError(t, err)
+	crc32Result, err := crc32Column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+	require.NoError(t, err)
+
+	// ASSERT
+	assert.Equal(t, "3e78b4cd8884f15622a00526308871c805dae8a9acb0652bf78c977cf5150ca85e0ae8de88be668d13e2542654dbe139afa6c914783f8dd6d70e78b47d41addc", sha512Result)
+	assert.Equal(t, "e8280b861670f4ba4536a84fa5e4e67e7515ab356215481bc35eb3707f3a05b6", sha3Result)
+	assert.Equal(t, "0e6f94bc", crc32Result)
+}
+
+func TestHashColumn_singleRead(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	scanner := NewContentScanner()
+	sha224Column, err := scanner.HashColumn("sha224")
+	require.NoError(t, err)
+	adler32Column, err := scanner.HashColumn("adler32")
+	require.NoError(t, err)
+
+	// ACT
+	sha224Result, err := sha224Column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+	require.NoError(t, err)
+
+	// 最初の列で全アルゴリズムが計算済みなので、ファイルが無くなっても残りの列は取得できる
+	require.NoError(t, os.Remove(filePath))
+
+	adler32Result, err := adler32Column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+	require.NoError(t, err)
+
+	// ASSERT
+	assert.Equal(t, "bae3735e5822d8c30fafd70736316e7807f7cccf65e6e73c15f32a60", sha224Result)
+	assert.Equal(t, "075b01dd", adler32Result)
+}
+
+func TestHmacColumn(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	scanner := NewContentScanner()
+	sha256Column, err := scanner.HashColumn("sha256")
+	require.NoError(t, err)
+	hmacColumn, err := scanner.HMACColumn("sha256", []byte("secret"))
+	require.NoError(t, err)
+	hmacSha3Column, err := scanner.HMACColumn("sha3-256", []byte("secret"))
+	require.NoError(t, err)
+
+	// ACT
+	sha256Result, err := sha256Column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+	require.NoError(t, err)
+	hmacResult, err := hmacColumn.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+	require.NoError(t, err)
+	hmacSha3Result, err := hmacSha3Column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+	require.NoError(t, err)
+
+	// ASSERT
+	assert.Equal(t, "e9a92a2ed0d53732ac13b031a27b071814231c8633c9f41844ccba884d482b16", sha256Result)
+	assert.Equal(t, "29a146676adcc892b9297c6d7b325c0722115104f9b27b4faf93e10f0c643deb", hmacResult)
+	assert.Equal(t, "467cac93432829f7aa761643999ab43a2bbf4142af0545e6b4f47280ef6c028f", hmacSha3Result)
+}
+
+func TestHmacColumn_notCryptographic(t *testing.T) {
+
+	// ARRANGE
+	scanner := NewContentScanner()
+
+	// ACT
+	_, err := scanner.HMACColumn("crc32", []byte("secret"))
+
+	// ASSERT
+	require.EqualError(t, err, "HMAC is not supported for crc32")
+}
+
+func TestHashColumn_dir(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	info, err := os.Stat(temp)
+	require.NoError(t, err)
+
+	scanner := NewContentScanner()
+	column, err := scanner.HashColumn("sha512")
+	require.NoError(t, err)
+
+	// ACT
+	result, err := column.Value(&Entry{Path: temp, BaseDir: temp, Info: info})
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "", result)
+}
+
+func TestHashColumn_unknown(t *testing.T) {
+
+	// ARRANGE
+	scanner := NewContentScanner()
+
+	// ACT
+	_, err := scanner.HashColumn("sha4")
+
+	// ASSERT
+	require.EqualError(t, err, "unknown hash algorithm: sha4")
+}
+
+func TestHashColumn_canceled(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "a.txt", "ABC", "")
+
+	scanner := NewContentScanner()
+	column, err := scanner.HashColumn("sha256")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	// ACT
+	_, err = column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info, ctx: ctx})
+
+	// ASSERT
+	assert.ErrorIs(t, err, context.Canceled)
+}
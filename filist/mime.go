@@ -0,0 +1,165 @@
+package filist
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// 判定に使うファイル先頭のサイズ (http.DetectContentType が見る範囲)
+const headSize = 512
+
+// fileSignature ファイル先頭のマジックバイトによる判定
+type fileSignature struct {
+	offset int
+	magic  string
+	mime   string
+}
+
+// http.DetectContentType で判定できない、または判定が大まかなものを先に見る
+var fileSignatures = []fileSignature{
+	{0, "%PDF-", "application/pdf"},
+	{0, "PK\x03\x04", "application/zip"},
+	{0, "PK\x05\x06", "application/zip"},
+	{0, "\x1f\x8b", "application/gzip"},
+	{0, "BZh", "application/x-bzip2"},
+	{0, "\xfd7zXZ\x00", "application/x-xz"},
+	{0, "\x28\xb5\x2f\xfd", "application/zstd"},
+	{0, "7z\xbc\xaf\x27\x1c", "application/x-7z-compressed"},
+	{0, "Rar!\x1a\x07", "application/vnd.rar"},
+	{257, "ustar", "application/x-tar"},
+	{0, "\x7fELF", "application/x-elf"},
+	{0, "\xcf\xfa\xed\xfe", "application/x-mach-binary"},
+	{0, "\xce\xfa\xed\xfe", "application/x-mach-binary"},
+	{0, "\xfe\xed\xfa\xcf", "application/x-mach-binary"},
+	{0, "\xfe\xed\xfa\xce", "application/x-mach-binary"},
+	{0, "MZ", "application/vnd.microsoft.portable-executable"},
+	{0, "\x00asm", "application/wasm"},
+	{0, "SQLite format 3\x00", "application/vnd.sqlite3"},
+	{0, "\x89PNG\r\n\x1a\n", "image/png"},
+	{0, "\xff\xd8\xff", "image/jpeg"},
+	{0, "GIF87a", "image/gif"},
+	{0, "GIF89a", "image/gif"},
+}
+
+// MimeColumn ファイル先頭の内容から判定したContent-Typeの列
+func MimeColumn() Column {
+
+	return NewColumn("mime", ColumnTypeString, func(entry *Entry) (string, error) {
+		return getMime(entry.Context(), entry.Path, entry.Info)
+	})
+}
+
+// TextBinaryColumn テキストファイルかバイナリファイルかの列
+func TextBinaryColumn() Column {
+
+	return NewColumn("text-binary", ColumnTypeString, func(entry *Entry) (string, error) {
+		return getTextBinary(entry.Context(), entry.Path, entry.Info)
+	})
+}
+
+func getMime(ctx context.Context, filePath string, info os.FileInfo) (string, error) {
+
+	if !hasContent(filePath, info) {
+		return "", nil
+	}
+
+	head, err := readHead(ctx, filePath, info)
+	if err != nil {
+		return "", err
+	}
+
+	return detectContentType(head), nil
+}
+
+func getTextBinary(ctx context.Context, filePath string, info os.FileInfo) (string, error) {
+
+	if !hasContent(filePath, info) {
+		return "", nil
+	}
+
+	head, err := readHead(ctx, filePath, info)
+	if err != nil {
+		return "", err
+	}
+
+	if isBinary(head) {
+		return "binary", nil
+	}
+	return "text", nil
+}
+
+// readHead 先頭の部分を読む (内容全体と同じく、読み込みの速度の上限を適用する)
+func readHead(ctx context.Context, filePath string, info os.FileInfo) ([]byte, error) {
+
+	f, err := openContent(ctx, filePath, info)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, headSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	return head[:n], nil
+}
+
+func matchSignature(head []byte) (fileSignature, bool) {
+
+	for _, signature := range fileSignatures {
+		end := signature.offset + len(signature.magic)
+		if end <= len(head) && string(head[signature.offset:end]) == signature.magic {
+			return signature, true
+		}
+	}
+
+	return fileSignature{}, false
+}
+
+func detectContentType(head []byte) string {
+
+	if signature, ok := matchSignature(head); ok {
+		return signature.mime
+	}
+
+	return http.DetectContentType(head)
+}
+
+// isBinary ファイル先頭の内容からバイナリファイルかを判定する
+func isBinary(head []byte) bool {
+
+	if _, ok := matchSignature(head); ok {
+		return true
+	}
+
+	// BOM付きのUTF-16など、テキストとして判定されたものはNULを含んでもテキスト
+	if strings.HasPrefix(http.DetectContentType(head), "text/") {
+		return false
+	}
+
+	if bytes.IndexByte(head, 0) != -1 {
+		return true
+	}
+
+	if utf8.Valid(head) {
+		return false
+	}
+
+	if len(head) == headSize {
+		// 先頭のみ読んでいるので、末尾で文字が途切れている場合は除いて判定
+		for i := 1; i < utf8.UTFMax; i++ {
+			if utf8.Valid(head[:len(head)-i]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
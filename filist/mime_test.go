@@ -0,0 +1,116 @@
+package filist
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMime(t *testing.T) {
+
+	temp := t.TempDir()
+
+	tests := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{"a.pdf", "%PDF-1.7\n", "application/pdf"},
+		{"a.zip", "PK\x03\x04\x14\x00", "application/zip"},
+		{"a.gz", "\x1f\x8b\x08\x00", "application/gzip"},
+		{"a.elf", "\x7fELF\x02\x01\x01", "application/x-elf"},
+		{"a.db", "SQLite format 3\x00\x10\x00", "application/vnd.sqlite3"},
+		{"a.png", "\x89PNG\r\n\x1a\n\x00\x00", "image/png"},
+		{"a.jpg", "\xff\xd8\xff\xe0\x00\x10JFIF", "image/jpeg"},
+		{"a.tar", strings.Repeat("\x00", 257) + "ustar\x0000", "application/x-tar"},
+		{"a.txt", "hello\n", "text/plain; charset=utf-8"},
+		{"a.html", "<!DOCTYPE html><html></html>", "text/html; charset=utf-8"},
+		// 拡張子は見ない
+		{"fake.txt", "%PDF-1.4", "application/pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ARRANGE
+			filePath, info := setupFile(t, temp, tt.name, tt.contents, "")
+
+			// ACT
+			result, err := getMime(t.Context(), filePath, info)
+
+			// ASSERT
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestGetMime_dir(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	info, err := os.Stat(temp)
+	require.NoError(t, err)
+
+	// ACT
+	result, err := getMime(t.Context(), temp, info)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "", result)
+}
+
+func TestGetTextBinary(t *testing.T) {
+
+	temp := t.TempDir()
+
+	tests := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{"ascii.txt", "hello\r\nworld\n", "text"},
+		{"utf8.txt", "テスト\n", "text"},
+		{"empty.txt", "", "text"},
+		{"utf16.txt", "\xff\xfeA\x00B\x00", "text"},
+		{"escape.txt", "\x1b[31mred\x1b[0m\n", "text"},
+		{"nul.bin", "abc\x00def", "binary"},
+		{"a.elf", "\x7fELF\x02\x01\x01", "binary"},
+		{"a.png", "\x89PNG\r\n\x1a\n", "binary"},
+		{"control.bin", "\x01\x02\xe9\xe8", "binary"},
+		// 読み込んだ範囲の末尾でマルチバイト文字が途切れていてもテキスト
+		{"long.txt", strings.Repeat("a", headSize-1) + "テスト", "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ARRANGE
+			filePath, info := setupFile(t, temp, tt.name, tt.contents, "")
+
+			// ACT
+			result, err := getTextBinary(t.Context(), filePath, info)
+
+			// ASSERT
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestReadHead(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", strings.Repeat("x", headSize*3), "")
+
+	// ACT
+	result, err := readHead(t.Context(), filePath, info)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Len(t, result, headSize)
+}
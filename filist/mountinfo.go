@@ -0,0 +1,111 @@
+package filist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const mountInfoFile = "/proc/self/mountinfo"
+
+// mountPoint マウントポイントとそのファイルシステムの種類
+type mountPoint struct {
+	path   string
+	fsType string
+}
+
+// FindExcludedMounts 指定された種類のファイルシステムのマウントポイントを返す
+func FindExcludedMounts(fsTypes []string) (map[string]bool, error) {
+
+	f, err := os.Open(mountInfoFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("--exclude-fstype is not supported on this platform (%s not found)", mountInfoFile)
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	mounts, err := readMountInfo(f)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := map[string]bool{}
+	for _, mount := range mounts {
+		for _, fsType := range fsTypes {
+			if mount.fsType == strings.TrimSpace(fsType) {
+				excluded[mount.path] = true
+			}
+		}
+	}
+
+	return excluded, nil
+}
+
+// readMountInfo /proc/self/mountinfo の形式を読み込む
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+//
+// 5番目がマウントポイント、" - " の後ろがファイルシステムの種類
+func readMountInfo(r io.Reader) ([]mountPoint, error) {
+
+	var mounts []mountPoint
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		separator := -1
+		for i, field := range fields {
+			if field == "-" {
+				separator = i
+				break
+			}
+		}
+
+		if separator < 5 || separator+1 >= len(fields) {
+			return nil, fmt.Errorf("invalid mountinfo line: %s", scanner.Text())
+		}
+
+		mounts = append(mounts, mountPoint{
+			path:   unescapeMountPath(fields[4]),
+			fsType: fields[separator+1],
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return mounts, nil
+}
+
+// unescapeMountPath 空白などが8進数でエスケープ(\040)されているのを戻す
+func unescapeMountPath(path string) string {
+
+	if !strings.Contains(path, "\\") {
+		return path
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+4 <= len(path) {
+			if value, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+
+	return b.String()
+}
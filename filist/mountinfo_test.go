@@ -0,0 +1,55 @@
+package filist
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadMountInfo(t *testing.T) {
+
+	// ARRANGE
+	mountInfo := `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+23 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+24 22 0:22 / /sys rw,nosuid,nodev,noexec,relatime shared:2 - sysfs sysfs rw
+36 22 0:31 / /mnt/nfs\040share rw,relatime - nfs4 server:/export rw,vers=4.2
+37 22 0:32 / /tmp rw master:1 propagate_from:2 - tmpfs tmpfs rw
+`
+
+	// ACT
+	mounts, err := readMountInfo(strings.NewReader(mountInfo))
+
+	// ASSERT
+	require.NoError(t, err)
+
+	expected := []mountPoint{
+		{"/", "ext4"},
+		{"/proc", "proc"},
+		{"/sys", "sysfs"},
+		{"/mnt/nfs share", "nfs4"},
+		{"/tmp", "tmpfs"},
+	}
+	assert.Equal(t, expected, mounts)
+}
+
+func TestReadMountInfo_invalid(t *testing.T) {
+
+	// ARRANGE
+	mountInfo := "22 1 8:1 / / rw,relatime ext4 /dev/sda1 rw\n"
+
+	// ACT
+	_, err := readMountInfo(strings.NewReader(mountInfo))
+
+	// ASSERT
+	require.EqualError(t, err, "invalid mountinfo line: 22 1 8:1 / / rw,relatime ext4 /dev/sda1 rw")
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+
+	assert.Equal(t, "/mnt/data", unescapeMountPath("/mnt/data"))
+	assert.Equal(t, "/mnt/my data", unescapeMountPath(`/mnt/my\040data`))
+	assert.Equal(t, "/mnt/a\tb\\c", unescapeMountPath(`/mnt/a\011b\134c`))
+	assert.Equal(t, `/mnt/a\04`, unescapeMountPath(`/mnt/a\04`))
+}
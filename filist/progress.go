@@ -0,0 +1,83 @@
+package filist
+
+import (
+	"io/fs"
+	"sync/atomic"
+)
+
+// Progress 走査と読み込みの進捗
+// 走査中に別のgoroutineから参照できるように、値はアトミックに更新する
+type Progress struct {
+	files      atomic.Int64
+	bytes      atomic.Int64
+	path       atomic.Pointer[string]
+	totalFiles atomic.Int64
+	totalBytes atomic.Int64
+	totalKnown atomic.Bool
+}
+
+// ProgressStatus ある時点での進捗
+type ProgressStatus struct {
+	// 見つかったファイル数
+	Files int64
+	// 内容を読み込んだバイト数 (複数の列で読み込んだ場合はその合計)
+	Bytes int64
+	// 最後に見つかったエントリのパス
+	Path string
+	// 事前の走査で求めた全体のファイル数、サイズ (求まっていない場合は TotalKnown が false)
+	TotalFiles int64
+	TotalBytes int64
+	TotalKnown bool
+}
+
+func NewProgress() *Progress {
+	return &Progress{}
+}
+
+// Write 読み込んだ内容のバイト数を数える (nilの場合は何もしない)
+func (p *Progress) Write(b []byte) (int, error) {
+
+	if p != nil {
+		p.bytes.Add(int64(len(b)))
+	}
+	return len(b), nil
+}
+
+// SetTotal 事前の走査で求めた全体のファイル数、サイズを設定する
+func (p *Progress) SetTotal(files int64, bytes int64) {
+
+	p.totalFiles.Store(files)
+	p.totalBytes.Store(bytes)
+	p.totalKnown.Store(true)
+}
+
+// Status 現時点の進捗を返す
+func (p *Progress) Status() ProgressStatus {
+
+	status := ProgressStatus{
+		Files:      p.files.Load(),
+		Bytes:      p.bytes.Load(),
+		TotalFiles: p.totalFiles.Load(),
+		TotalBytes: p.totalBytes.Load(),
+		TotalKnown: p.totalKnown.Load(),
+	}
+
+	if path := p.path.Load(); path != nil {
+		status.Path = *path
+	}
+
+	return status
+}
+
+// addEntry 走査で見つかったエントリを数える (nilの場合は何もしない)
+func (p *Progress) addEntry(path string, info fs.FileInfo) {
+
+	if p == nil {
+		return
+	}
+
+	p.path.Store(&path)
+	if !info.IsDir() {
+		p.files.Add(1)
+	}
+}
@@ -0,0 +1,57 @@
+package filist
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFile(t, temp, "a.txt", "AAA", "")
+	setupFile(t, filepath.Join(temp, "b"), "b.txt", "BBBBB", "")
+
+	progress := NewProgress()
+	walker := &Walker{IncludeDirectories: true, Progress: progress}
+
+	scanner := NewContentScanner()
+	scanner.Progress = progress
+	md5Column, err := scanner.HashColumn("md5")
+	require.NoError(t, err)
+
+	columns := []Column{RelPathColumn(), md5Column, TreeHashColumnWithProgress(progress)}
+
+	// ACT
+	walkAndFormat(t, columns, func(fn WalkFunc) error {
+		return walker.Walk(t.Context(), []string{temp}, fn)
+	})
+	progress.SetTotal(2, 8)
+
+	// ASSERT
+	status := progress.Status()
+	assert.Equal(t, int64(2), status.Files)
+	// ハッシュとツリーハッシュで、ファイルごとに2回ずつ読み込む
+	// (ディレクトリのツリーハッシュで読み込んだ b.txt は、キャッシュを使うので読み込まない)
+	assert.Equal(t, int64(16), status.Bytes)
+	assert.Equal(t, filepath.Join(temp, "b", "b.txt"), status.Path)
+	assert.Equal(t, int64(2), status.TotalFiles)
+	assert.Equal(t, int64(8), status.TotalBytes)
+	assert.True(t, status.TotalKnown)
+}
+
+func TestProgress_Nil(t *testing.T) {
+
+	// ARRANGE
+	var progress *Progress
+
+	// ACT
+	n, err := progress.Write([]byte("abc"))
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
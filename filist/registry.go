@@ -0,0 +1,275 @@
+package filist
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ColumnConfig 列の作成時に共有する設定
+type ColumnConfig struct {
+	// ファイルの内容から計算する列をまとめて計算する
+	Scanner *ContentScanner
+	// サイズの表示形式
+	SizeFormat SizeFormat
+	// 単位付きのサイズの小数点以下の桁数
+	Precision int
+	// HMACの鍵
+	HMACKey []byte
+	// 読み込んだバイト数を数える進捗
+	Progress *Progress
+}
+
+// ColumnDefinition 名前で指定できる列の定義
+type ColumnDefinition struct {
+	Name        string
+	Description string
+	// 指定できるオプションの説明 (オプションが無い列は空)
+	Options string
+	// option は列の名前の後に ':' で続けて指定された値 (指定が無ければ空)
+	New func(config *ColumnConfig, option string) (Column, error)
+}
+
+// Registry 名前で指定できる列の一覧
+type Registry struct {
+	definitions []ColumnDefinition
+}
+
+const lengthOptions = "N (first N characters)"
+
+// NewRegistry 組み込みの列を登録した一覧を作成する
+func NewRegistry() *Registry {
+
+	r := &Registry{}
+
+	r.registerSimple("rel", "Relative path", RelPathColumn)
+	r.registerSimple("abs", "Absolute path", AbsPathColumn)
+	r.registerSimple("root", "Root (directory or file as specified in the arguments)", RootColumn)
+	r.registerSimple("root-abs", "Absolute path of root", RootAbsPathColumn)
+	r.Register(ColumnDefinition{
+		Name:        "size",
+		Description: "File size",
+		Options:     "bytes|human|si",
+		New: func(config *ColumnConfig, option string) (Column, error) {
+			switch option {
+			case "":
+				return SizeColumn(config.SizeFormat, config.Precision), nil
+			case "bytes":
+				return SizeColumn(SizeFormatBytes, config.Precision), nil
+			case "human":
+				return SizeColumn(SizeFormatIEC, config.Precision), nil
+			case "si":
+				return SizeColumn(SizeFormatSI, config.Precision), nil
+			}
+			return nil, invalidOption("size", option)
+		},
+	})
+	r.Register(ColumnDefinition{
+		Name:        "mtime",
+		Description: "Modification time",
+		Options:     "iso|unix",
+		New: func(config *ColumnConfig, option string) (Column, error) {
+			switch option {
+			case "", "iso":
+				return MtimeColumn(), nil
+			case "unix":
+				return UnixMtimeColumn(), nil
+			}
+			return nil, invalidOption("mtime", option)
+		},
+	})
+	r.registerSimple("depth", "Directory level", DepthColumn)
+
+	for _, name := range []string{"lines", "words", "chars"} {
+		r.Register(ColumnDefinition{
+			Name:        name,
+			Description: "Number of " + name,
+			New: func(config *ColumnConfig, option string) (Column, error) {
+				return config.Scanner.CountColumn(name), nil
+			},
+		})
+	}
+
+	r.Register(ColumnDefinition{
+		Name:        "encoding",
+		Description: "Text encoding",
+		New: func(config *ColumnConfig, option string) (Column, error) {
+			return config.Scanner.EncodingColumn("encoding"), nil
+		},
+	})
+	r.Register(ColumnDefinition{
+		Name:        "eol",
+		Description: "Line ending",
+		New: func(config *ColumnConfig, option string) (Column, error) {
+			return config.Scanner.EncodingColumn("eol"), nil
+		},
+	})
+
+	r.registerSimple("mime", "Content type detected from the head of the file", MimeColumn)
+	r.registerSimple("text-binary", "Whether the file is text or binary", TextBinaryColumn)
+
+	for _, algorithm := range hashAlgorithms {
+		r.Register(ColumnDefinition{
+			Name:        algorithm.name,
+			Description: "Hash (" + algorithm.name + ")",
+			Options:     lengthOptions,
+			New: func(config *ColumnConfig, option string) (Column, error) {
+				return truncateColumn(option)(config.Scanner.HashColumn(algorithm.name))
+			},
+		})
+	}
+
+	for _, algorithm := range hashAlgorithms {
+		if !algorithm.cryptographic {
+			continue
+		}
+
+		name := "hmac-" + algorithm.name
+		r.Register(ColumnDefinition{
+			Name:        name,
+			Description: "HMAC (" + algorithm.name + ")",
+			Options:     lengthOptions,
+			New: func(config *ColumnConfig, option string) (Column, error) {
+				if len(config.HMACKey) == 0 {
+					return nil, fmt.Errorf("key is required for %s", name)
+				}
+				return truncateColumn(option)(config.Scanner.HMACColumn(algorithm.name, config.HMACKey))
+			},
+		})
+	}
+
+	r.Register(ColumnDefinition{
+		Name:        "tree-hash",
+		Description: "Merkle tree hash",
+		Options:     lengthOptions,
+		New: func(config *ColumnConfig, option string) (Column, error) {
+			return truncateColumn(option)(TreeHashColumnWithProgress(config.Progress), nil)
+		},
+	})
+
+	return r
+}
+
+// registerSimple オプションの無い列を登録する
+func (r *Registry) registerSimple(name string, description string, column func() Column) {
+
+	r.Register(ColumnDefinition{
+		Name:        name,
+		Description: description,
+		New: func(config *ColumnConfig, option string) (Column, error) {
+			return column(), nil
+		},
+	})
+}
+
+// Register 列を登録する (同じ名前の列は置き換える)
+func (r *Registry) Register(definition ColumnDefinition) {
+
+	for i := range r.definitions {
+		if r.definitions[i].Name == definition.Name {
+			r.definitions[i] = definition
+			return
+		}
+	}
+
+	r.definitions = append(r.definitions, definition)
+}
+
+// Lookup 指定の名前の列の定義を返す
+func (r *Registry) Lookup(name string) (ColumnDefinition, bool) {
+
+	for _, definition := range r.definitions {
+		if definition.Name == name {
+			return definition, true
+		}
+	}
+
+	return ColumnDefinition{}, false
+}
+
+// Definitions 登録順に列の定義を返す
+func (r *Registry) Definitions() []ColumnDefinition {
+	return append([]ColumnDefinition(nil), r.definitions...)
+}
+
+// New 指定の名前の列を作成する
+func (r *Registry) New(name string, option string, config *ColumnConfig) (Column, error) {
+
+	definition, ok := r.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown column: %s", name)
+	}
+
+	if option != "" && definition.Options == "" {
+		return nil, fmt.Errorf("column %s does not take an option: %s", name, option)
+	}
+
+	if config.Scanner == nil {
+		config.Scanner = NewContentScanner()
+		config.Scanner.Progress = config.Progress
+	}
+
+	return definition.New(config, option)
+}
+
+// Parse カンマ区切りの列の指定 (例: rel,size:human,mtime:unix,sha256:12) から列を作成する
+func (r *Registry) Parse(spec string, config *ColumnConfig) ([]Column, error) {
+
+	var columns []Column
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, fmt.Errorf("empty column in: %s", spec)
+		}
+
+		name, option, _ := strings.Cut(item, ":")
+
+		column, err := r.New(name, option, config)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+// ColumnNames 列の指定に含まれる列の名前 (オプションは除く)
+func ColumnNames(spec string) []string {
+
+	var names []string
+	for _, item := range strings.Split(spec, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(item), ":")
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func invalidOption(name string, option string) error {
+	return fmt.Errorf("invalid option for %s: %s", name, option)
+}
+
+// truncateColumn オプションで長さが指定されていれば、値を先頭の指定文字数に切り詰める
+func truncateColumn(option string) func(column Column, err error) (Column, error) {
+
+	return func(column Column, err error) (Column, error) {
+		if err != nil || option == "" {
+			return column, err
+		}
+
+		length, err := strconv.Atoi(option)
+		if err != nil || length < 1 {
+			return nil, invalidOption(column.Name(), option)
+		}
+
+		return NewColumn(column.Name(), column.Type(), func(entry *Entry) (string, error) {
+			value, err := column.Value(entry)
+			if err != nil || len(value) <= length {
+				return value, err
+			}
+			return value[:length], nil
+		}), nil
+	}
+}
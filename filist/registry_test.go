@@ -0,0 +1,186 @@
+package filist
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_New(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "a.txt", "A\nB\n", "2021-02-03T04:05:06")
+
+	registry := NewRegistry()
+	config := &ColumnConfig{}
+
+	entry := &Entry{Path: filePath, BaseDir: temp, Depth: 1, Info: info}
+
+	var columns []Column
+	for _, name := range []string{"rel", "size", "mtime", "depth", "lines", "md5"} {
+		column, err := registry.New(name, "", config)
+		require.NoError(t, err)
+		columns = append(columns, column)
+	}
+
+	// ACT
+	values, err := Values(entry, columns)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "4", "2021-02-03T04:05:06.000000+00:00", "1", "2", "d09f55e3d6d2265b7507cc6027071d0a"}, values)
+	assert.NotNil(t, config.Scanner)
+}
+
+func TestRegistry_New_Unknown(t *testing.T) {
+
+	// ARRANGE
+	registry := NewRegistry()
+
+	// ACT
+	_, err := registry.New("xxx", "", &ColumnConfig{})
+
+	// ASSERT
+	assert.EqualError(t, err, "unknown column: xxx")
+}
+
+func TestRegistry_New_HMACWithoutKey(t *testing.T) {
+
+	// ARRANGE
+	registry := NewRegistry()
+
+	// ACT
+	_, err := registry.New("hmac-sha256", "", &ColumnConfig{})
+
+	// ASSERT
+	assert.EqualError(t, err, "key is required for hmac-sha256")
+}
+
+func TestRegistry_Register(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "a.txt", "A", "")
+
+	registry := NewRegistry()
+	count := len(registry.Definitions())
+
+	// ACT
+	registry.Register(ColumnDefinition{
+		Name:        "rel",
+		Description: "Upper case name",
+		New: func(config *ColumnConfig, option string) (Column, error) {
+			return NewColumn("rel", ColumnTypeString, func(entry *Entry) (string, error) {
+				return "A.TXT", nil
+			}), nil
+		},
+	})
+	registry.Register(ColumnDefinition{
+		Name:        "ext",
+		Description: "Extension",
+		New: func(config *ColumnConfig, option string) (Column, error) {
+			return NewColumn("ext", ColumnTypeString, func(entry *Entry) (string, error) {
+				return "txt", nil
+			}), nil
+		},
+	})
+
+	// ASSERT
+	definitions := registry.Definitions()
+	require.Len(t, definitions, count+1)
+	assert.Equal(t, "rel", definitions[0].Name)
+	assert.Equal(t, "Upper case name", definitions[0].Description)
+	assert.Equal(t, "ext", definitions[len(definitions)-1].Name)
+
+	entry := &Entry{Path: filePath, BaseDir: temp, Info: info}
+	for name, expected := range map[string]string{"rel": "A.TXT", "ext": "txt"} {
+		column, err := registry.New(name, "", &ColumnConfig{})
+		require.NoError(t, err)
+
+		value, err := column.Value(entry)
+		require.NoError(t, err)
+		assert.Equal(t, expected, value)
+	}
+}
+
+func TestRegistry_Definitions(t *testing.T) {
+
+	// ARRANGE
+	registry := NewRegistry()
+
+	// ACT
+	definitions := registry.Definitions()
+
+	// ASSERT
+	var names []string
+	for _, definition := range definitions {
+		names = append(names, definition.Name)
+		assert.NotEmpty(t, definition.Description)
+	}
+
+	assert.Equal(t, []string{"rel", "abs", "root", "root-abs", "size", "mtime", "depth"}, names[:7])
+	assert.Contains(t, names, "sha256")
+	assert.Contains(t, names, "hmac-sha256")
+	assert.NotContains(t, names, "hmac-crc32")
+	assert.Equal(t, "tree-hash", names[len(names)-1])
+}
+
+func TestRegistry_Parse(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "a.txt", strings.Repeat("x", 2048), "2021-02-03T04:05:06")
+
+	registry := NewRegistry()
+	entry := &Entry{Path: filePath, BaseDir: temp, Info: info}
+
+	// ACT
+	columns, err := registry.Parse("rel, size:human,size:bytes,mtime:unix,mtime:iso,md5:8,crc32:100", &ColumnConfig{Precision: 1})
+
+	// ASSERT
+	require.NoError(t, err)
+
+	values, err := Values(entry, columns)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "2.0 KiB", "2048", "1612325106", "2021-02-03T04:05:06.000000+00:00", "cfb767f2", "6f5234cf"}, values)
+
+	var types []ColumnType
+	for _, column := range columns {
+		types = append(types, column.Type())
+	}
+	assert.Equal(t, []ColumnType{ColumnTypeString, ColumnTypeString, ColumnTypeNumber, ColumnTypeNumber, ColumnTypeTime, ColumnTypeString, ColumnTypeString}, types)
+}
+
+func TestRegistry_Parse_Error(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		spec     string
+		expected string
+	}{
+		{"unknown", "rel,xxx", "unknown column: xxx"},
+		{"empty", "rel,,size", "empty column in: rel,,size"},
+		{"no option", "rel:x", "column rel does not take an option: x"},
+		{"size", "size:kb", "invalid option for size: kb"},
+		{"mtime", "mtime:date", "invalid option for mtime: date"},
+		{"length", "sha256:0", "invalid option for sha256: 0"},
+		{"not number", "tree-hash:x", "invalid option for tree-hash: x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ARRANGE
+			registry := NewRegistry()
+
+			// ACT
+			_, err := registry.Parse(tt.spec, &ColumnConfig{})
+
+			// ASSERT
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
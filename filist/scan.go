@@ -0,0 +1,101 @@
+package filist
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// contentAnalyzer ファイルの内容を受け取り、そこから列の値を計算する
+type contentAnalyzer interface {
+	io.Writer
+	// result 計算した値を列の名前ごとに返す
+	result() map[string]string
+}
+
+type analyzerFactory struct {
+	name string
+	new  func() contentAnalyzer
+}
+
+// ContentScanner ファイルの内容から計算する列(ハッシュや行数など)を、1回の読み込みでまとめて計算する
+type ContentScanner struct {
+	// 読み込んだバイト数を数える進捗 (nilの場合は数えない)
+	Progress  *Progress
+	factories []analyzerFactory
+	filePath  string
+	values    map[string]string
+}
+
+func NewContentScanner() *ContentScanner {
+	return &ContentScanner{}
+}
+
+// add 計算対象を加える (同じ名前のものは1つだけ)
+func (s *ContentScanner) add(name string, new func() contentAnalyzer) {
+
+	for _, factory := range s.factories {
+		if factory.name == name {
+			return
+		}
+	}
+
+	s.factories = append(s.factories, analyzerFactory{name: name, new: new})
+}
+
+// entryColumn 計算した値のうち、指定の名前の値を表示する列 (読み込みは走査のコンテキストで中断する)
+func (s *ContentScanner) entryColumn(name string, columnType ColumnType) Column {
+
+	return NewColumn(name, columnType, func(entry *Entry) (string, error) {
+		return s.value(entry.Context(), name, entry.Path, entry.Info)
+	})
+}
+
+func (s *ContentScanner) value(ctx context.Context, name string, filePath string, info os.FileInfo) (string, error) {
+
+	if !hasContent(filePath, info) {
+		return "", nil
+	}
+
+	values, err := s.scan(ctx, filePath, info)
+	if err != nil {
+		return "", err
+	}
+
+	return values[name], nil
+}
+
+func (s *ContentScanner) scan(ctx context.Context, filePath string, info os.FileInfo) (map[string]string, error) {
+
+	if s.values != nil && s.filePath == filePath {
+		// 同じファイルの別の列から呼ばれた場合は計算済みの値を返す
+		return s.values, nil
+	}
+
+	analyzers := make([]contentAnalyzer, len(s.factories))
+	writers := make([]io.Writer, len(s.factories))
+	for i, factory := range s.factories {
+		analyzers[i] = factory.new()
+		writers[i] = analyzers[i]
+	}
+
+	if s.Progress != nil {
+		writers = append(writers, s.Progress)
+	}
+
+	if err := readContent(ctx, filePath, info, writers...); err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, analyzer := range analyzers {
+		for name, value := range analyzer.result() {
+			values[name] = value
+		}
+	}
+
+	s.filePath = filePath
+	s.values = values
+
+	return values, nil
+}
@@ -0,0 +1,160 @@
+package filist
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// 読み込みの途中でページキャッシュを破棄する間隔 (大きなファイルでキャッシュが溢れないように)
+const dropCacheInterval = 8 * 1024 * 1024
+
+// Throttle 走査と読み込みの速度の上限
+// 稼働中のサーバで、ディスクの帯域やページキャッシュを使い切らないようにする
+type Throttle struct {
+	bytes     *rateLimiter
+	files     *rateLimiter
+	dropCache bool
+}
+
+// NewThrottle bytesPerSec は1秒あたりの読み込みバイト数、filesPerSec は1秒あたりのファイル数の上限 (0は無制限)
+// dropCache を指定すると、読み込んだファイルのページキャッシュを破棄するようOSに伝える (対応しているプラットフォームのみ)
+func NewThrottle(bytesPerSec int64, filesPerSec float64, dropCache bool) *Throttle {
+
+	return &Throttle{
+		bytes:     newRateLimiter(float64(bytesPerSec)),
+		files:     newRateLimiter(filesPerSec),
+		dropCache: dropCache,
+	}
+}
+
+type throttleKey struct{}
+
+// withContext 走査中のエントリの内容の読み込みにも上限を適用するため、コンテキストに設定する
+func (t *Throttle) withContext(ctx context.Context) context.Context {
+
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, throttleKey{}, t)
+}
+
+func throttleFromContext(ctx context.Context) *Throttle {
+
+	t, _ := ctx.Value(throttleKey{}).(*Throttle)
+	return t
+}
+
+// waitFile ファイル数の上限を超えないように待つ (nilの場合は待たない)
+func (t *Throttle) waitFile(ctx context.Context, info os.FileInfo) error {
+
+	if t == nil || info.IsDir() {
+		return nil
+	}
+	return t.files.wait(ctx, 1)
+}
+
+// reader 読み込みの速度を制限する (file はページキャッシュを破棄する対象で、OS上のファイルで無い場合はnil)
+func (t *Throttle) reader(ctx context.Context, r io.Reader, file *os.File) io.Reader {
+
+	if !t.dropCache {
+		file = nil
+	}
+
+	return &throttledReader{
+		ctx:      ctx,
+		r:        r,
+		throttle: t,
+		file:     file,
+	}
+}
+
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	throttle *Throttle
+	file     *os.File
+	// 読み込んだ位置と、ページキャッシュを破棄済みの位置
+	offset  int64
+	dropped int64
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+
+	n, err := r.r.Read(p)
+
+	if n > 0 {
+		r.offset += int64(n)
+		if r.file != nil && r.offset-r.dropped >= dropCacheInterval {
+			r.drop()
+		}
+
+		if err := r.throttle.bytes.wait(r.ctx, n); err != nil {
+			return n, err
+		}
+	}
+
+	if err == io.EOF && r.file != nil {
+		r.drop()
+	}
+
+	return n, err
+}
+
+func (r *throttledReader) drop() {
+
+	if r.offset > r.dropped {
+		dropCache(r.file, r.dropped, r.offset-r.dropped)
+		r.dropped = r.offset
+	}
+}
+
+// rateLimiter 1秒あたりの量の上限を超えないように待つ
+// 使っていなかった時間の分は、1秒分までまとめて使える
+type rateLimiter struct {
+	rate float64
+	mu   sync.Mutex
+	// 次の量が使えるようになる時刻
+	next time.Time
+}
+
+// newRateLimiter rate が0以下の場合は無制限 (nilを返す)
+func newRateLimiter(rate float64) *rateLimiter {
+
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: rate}
+}
+
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if earliest := now.Add(-time.Second); l.next.Before(earliest) {
+		l.next = earliest
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,247 @@
+package filist
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+
+	// ARRANGE
+	limiter := newRateLimiter(100)
+
+	// ACT
+	// 1秒分まではまとめて使えるので待たない
+	start := time.Now()
+	err := limiter.wait(t.Context(), 100)
+	require.NoError(t, err)
+	burst := time.Since(start)
+
+	err = limiter.wait(t.Context(), 20)
+	require.NoError(t, err)
+	elapsed := time.Since(start)
+
+	// ASSERT
+	assert.Less(t, burst, 100*time.Millisecond)
+	assert.GreaterOrEqual(t, elapsed, 150*time.Millisecond)
+}
+
+func TestRateLimiter_Canceled(t *testing.T) {
+
+	// ARRANGE
+	limiter := newRateLimiter(1)
+	require.NoError(t, limiter.wait(t.Context(), 1))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	// ACT
+	err := limiter.wait(ctx, 10)
+
+	// ASSERT
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRateLimiter_Unlimited(t *testing.T) {
+
+	// ARRANGE
+	limiter := newRateLimiter(0)
+
+	// ACT
+	err := limiter.wait(t.Context(), 1000)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Nil(t, limiter)
+}
+
+func TestThrottle_Files(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFile(t, temp, "a.txt", "A", "")
+	setupFile(t, temp, "b.txt", "B", "")
+	setupFile(t, temp, "c.txt", "C", "")
+
+	walker := &Walker{Throttle: NewThrottle(0, 2, false)}
+
+	// ACT
+	start := time.Now()
+	result := walkAndFormat(t, []Column{RelPathColumn()}, func(fn WalkFunc) error {
+		return walker.Walk(t.Context(), []string{temp}, fn)
+	})
+	elapsed := time.Since(start)
+
+	// ASSERT
+	assert.Equal(t, "a.txt\nb.txt\nc.txt\n", result)
+	// 2ファイルまではまとめて使えるので、3ファイル目で0.5秒待つ
+	assert.GreaterOrEqual(t, elapsed, 400*time.Millisecond)
+}
+
+func TestThrottle_Files_SkipEntry(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"} {
+		setupFile(t, temp, name, "", "")
+	}
+
+	walker := &Walker{Throttle: NewThrottle(0, 2, false)}
+
+	// ACT
+	var walked []string
+	start := time.Now()
+	err := walker.Walk(t.Context(), []string{temp}, func(entry *Entry) error {
+		walked = append(walked, entry.Info.Name())
+		if entry.Info.Name() != "e.txt" {
+			return SkipEntry
+		}
+		return nil
+	})
+	elapsed := time.Since(start)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"}, walked)
+	// 読み飛ばしたエントリは数えないので待たない
+	assert.Less(t, elapsed, 400*time.Millisecond)
+}
+
+func TestThrottle_Read(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	contents := strings.Repeat("x", 1536)
+	filePath, info := setupFile(t, temp, "a.txt", contents, "")
+
+	ctx := NewThrottle(1024, 0, true).withContext(t.Context())
+	column, err := NewContentScanner().HashColumn("sha256")
+	require.NoError(t, err)
+
+	// ACT
+	start := time.Now()
+	result, err := column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info, ctx: ctx})
+	elapsed := time.Since(start)
+
+	// ASSERT
+	require.NoError(t, err)
+
+	expected := sha256.Sum256([]byte(contents))
+	assert.Equal(t, hex.EncodeToString(expected[:]), result)
+	// 1秒分を超えた512バイトの分、0.5秒待つ
+	assert.GreaterOrEqual(t, elapsed, 400*time.Millisecond)
+}
+
+func TestThrottle_Read_Canceled(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "a.txt", strings.Repeat("x", 100), "")
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	ctx = NewThrottle(1, 0, false).withContext(ctx)
+	column, err := NewContentScanner().HashColumn("sha256")
+	require.NoError(t, err)
+
+	// ACT
+	_, err = column.Value(&Entry{Path: filePath, BaseDir: temp, Info: info, ctx: ctx})
+
+	// ASSERT
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestThrottle_ReadHead_Canceled(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "a.txt", strings.Repeat("x", 100), "")
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	ctx = NewThrottle(1, 0, false).withContext(ctx)
+
+	// ACT
+	// 先頭だけの読み込みにも上限を適用する
+	_, err := MimeColumn().Value(&Entry{Path: filePath, BaseDir: temp, Info: info, ctx: ctx})
+
+	// ASSERT
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestThrottle_Archive_Canceled(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	writeArchiveFile(t, filepath.Join(temp, "a.zip"), createZip(t, map[string]string{
+		"a.txt": "A",
+	}))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	walker := &Walker{Archives: true, Throttle: NewThrottle(1, 0, false)}
+
+	// ACT
+	// 列の値を計算しなくても、アーカイブの読み込みに上限を適用する
+	err := walker.Walk(ctx, []string{temp}, func(entry *Entry) error {
+		return nil
+	})
+
+	// ASSERT
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestThrottle_Archive_Entry(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	archivePath := filepath.Join(temp, "a.tar")
+	writeArchiveFile(t, archivePath, createTar(t, map[string]string{
+		"a.txt": "ABC",
+	}))
+
+	archiveInfo, err := os.Stat(archivePath)
+	require.NoError(t, err)
+
+	ctx := NewThrottle(1024, 0, false).withContext(t.Context())
+
+	var reader io.Reader
+	fn := func(entry *Entry) error {
+		f, err := openContent(entry.Context(), entry.Path, entry.Info)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		// tar内のエントリはランダムアクセスできる
+		reader = f.(*contentFile).Reader
+		return nil
+	}
+
+	walker := &Walker{Archives: true, Throttle: NewThrottle(1024, 0, false)}
+
+	// ACT
+	err = walker.Walk(t.Context(), []string{temp}, fn)
+
+	// ASSERT
+	require.NoError(t, err)
+
+	// アーカイブ内のエントリは、アーカイブの読み込みで数えるので、二重には数えない
+	assert.IsType(t, &contextReader{}, reader)
+
+	// アーカイブ自体の読み込みには上限を適用する
+	f, err := openContent(ctx, archivePath, archiveInfo)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.IsType(t, &throttledReader{}, f.(*contentFile).Reader)
+}
@@ -0,0 +1,232 @@
+package filist
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// treeHasher ディレクトリ配下全体を表すMerkleツリーのハッシュを計算する
+// ファイルは内容のSHA-256、ディレクトリは子要素の種類、パーミッション、ハッシュ、名前から計算する
+type treeHasher struct {
+	// ディレクトリのハッシュ計算時に求めた子孫のハッシュ (走査でその子孫に到達した時に使う)
+	// 走査で到達しなくなった分は破棄して、対象外の子孫のハッシュを持ち続けないようにする
+	cache map[string]*treeChildren
+	// cache のディレクトリを全て含む、最上位のディレクトリ
+	cacheRoot string
+	// 読み込んだバイト数を数える進捗
+	progress *Progress
+}
+
+// TreeHashColumn Merkleツリーのハッシュの列
+func TreeHashColumn() Column {
+	return TreeHashColumnWithProgress(nil)
+}
+
+// TreeHashColumnWithProgress 読み込んだバイト数を進捗に数える、Merkleツリーのハッシュの列
+func TreeHashColumnWithProgress(progress *Progress) Column {
+
+	hasher := newTreeHasher()
+	hasher.progress = progress
+
+	return NewColumn("tree-hash", ColumnTypeString, func(entry *Entry) (string, error) {
+		return hasher.value(entry.Context(), entry.Path, entry.Info)
+	})
+}
+
+// treeChildren ディレクトリの子要素のハッシュ (走査と同じ名前順)
+type treeChildren struct {
+	children []treeChild
+	// 走査でまだ到達していない、最初の子要素
+	next int
+}
+
+type treeChild struct {
+	name string
+	sum  string
+	dir  bool
+}
+
+func newTreeHasher() *treeHasher {
+	return &treeHasher{
+		cache: map[string]*treeChildren{},
+	}
+}
+
+func (t *treeHasher) value(ctx context.Context, filePath string, info os.FileInfo) (string, error) {
+
+	if sum, ok := t.cached(filePath); ok {
+		return sum, nil
+	}
+
+	sum, err := t.hash(ctx, filePath, info)
+	if err != nil {
+		return "", err
+	}
+
+	if t.cacheRoot == "" && len(t.cache) != 0 {
+		t.cacheRoot = filePath
+	}
+
+	return sum, nil
+}
+
+// cached 親ディレクトリのハッシュ計算時に求めたハッシュを返す
+// 走査は名前順なので、それより前の子要素(対象外で到達しなかったもの)のハッシュは破棄する
+func (t *treeHasher) cached(filePath string) (string, bool) {
+
+	if t.cacheRoot != "" && !inDir(t.cacheRoot, filePath) {
+		// 走査がキャッシュしたディレクトリの外に出たら、配下に戻ってくることは無い
+		clear(t.cache)
+		t.cacheRoot = ""
+		return "", false
+	}
+
+	dirPath := filepath.Dir(filePath)
+	children, ok := t.cache[dirPath]
+	if !ok {
+		return "", false
+	}
+
+	name := filepath.Base(filePath)
+	for children.next < len(children.children) && children.children[children.next].name < name {
+		t.evict(dirPath, children.children[children.next])
+		children.next++
+	}
+
+	sum := ""
+	found := false
+	if children.next < len(children.children) && children.children[children.next].name == name {
+		sum = children.children[children.next].sum
+		found = true
+		children.next++
+	}
+
+	if children.next == len(children.children) {
+		delete(t.cache, dirPath)
+	}
+
+	return sum, found
+}
+
+// evict 子要素がディレクトリの場合は、その配下のハッシュを全て破棄する
+func (t *treeHasher) evict(dirPath string, child treeChild) {
+
+	if !child.dir {
+		return
+	}
+
+	childPath := filepath.Join(dirPath, child.name)
+	children, ok := t.cache[childPath]
+	if !ok {
+		return
+	}
+	delete(t.cache, childPath)
+
+	for _, grandchild := range children.children[children.next:] {
+		t.evict(childPath, grandchild)
+	}
+}
+
+func (t *treeHasher) hash(ctx context.Context, filePath string, info os.FileInfo) (string, error) {
+
+	switch {
+	case isArchiveRoot(info):
+		// ディレクトリとして扱うアーカイブも、親ディレクトリのハッシュと合うようにファイルとして計算
+		return t.hashFile(ctx, filePath, info)
+	case info.IsDir():
+		return t.hashDir(ctx, filePath, info)
+	case info.Mode()&os.ModeSymlink != 0:
+		// シンボリックリンクはリンク先を辿らず、リンク先のパスをハッシュ対象に
+		target, err := readLink(filePath, info)
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256([]byte(target))
+		return hex.EncodeToString(sum[:]), nil
+	case info.Mode().IsRegular():
+		return t.hashFile(ctx, filePath, info)
+	default:
+		// デバイスやパイプなどは内容を読まない
+		sum := sha256.Sum256(nil)
+		return hex.EncodeToString(sum[:]), nil
+	}
+}
+
+// hashFile ファイルの内容のSHA-256
+func (t *treeHasher) hashFile(ctx context.Context, filePath string, info os.FileInfo) (string, error) {
+
+	digest := sha256.New()
+	if err := readContent(ctx, filePath, info, digest, t.progress); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+func (t *treeHasher) hashDir(ctx context.Context, dirPath string, info os.FileInfo) (string, error) {
+
+	// ReadDirはファイル名順で返すので、同じ内容であれば常に同じハッシュになる
+	entries, err := readDir(dirPath, info)
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.New()
+	children := &treeChildren{}
+
+	for _, entry := range entries {
+
+		info, err := entry.Info()
+		if err != nil {
+			return "", err
+		}
+
+		childPath := filepath.Join(dirPath, entry.Name())
+		sum, err := t.hash(ctx, childPath, info)
+		if err != nil {
+			return "", err
+		}
+		children.children = append(children.children, treeChild{name: entry.Name(), sum: sum, dir: info.IsDir()})
+
+		fmt.Fprintf(digest, "%s %04o %s %s\x00", treeNodeType(info), info.Mode().Perm(), sum, entry.Name())
+	}
+
+	if len(children.children) != 0 {
+		t.cache[dirPath] = children
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// inDir path が dirPath 自体か、その配下か
+func inDir(dirPath string, path string) bool {
+
+	if path == dirPath {
+		return true
+	}
+
+	prefix := dirPath
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, prefix)
+}
+
+func treeNodeType(info os.FileInfo) string {
+
+	switch {
+	case info.IsDir():
+		return "d"
+	case info.Mode()&os.ModeSymlink != 0:
+		return "l"
+	case info.Mode().IsRegular():
+		return "f"
+	default:
+		return "o"
+	}
+}
@@ -0,0 +1,220 @@
+package filist
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeHasher_file(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	// ACT
+	result, err := TreeHashColumn().Value(&Entry{Path: filePath, BaseDir: temp, Info: info})
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "e9a92a2ed0d53732ac13b031a27b071814231c8633c9f41844ccba884d482b16", result)
+}
+
+func TestTreeHasher_emptyDir(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	info, err := os.Stat(temp)
+	require.NoError(t, err)
+
+	// ACT
+	result, err := TreeHashColumn().Value(&Entry{Path: temp, BaseDir: temp, Info: info})
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", result)
+}
+
+func TestTreeHasher_sameTree(t *testing.T) {
+
+	// ARRANGE
+	temp1 := t.TempDir()
+	temp2 := t.TempDir()
+	setupFiles(t, temp1)
+	setupFiles(t, temp2)
+
+	// ACT
+	result1 := treeHash(t, temp1)
+	result2 := treeHash(t, temp2)
+
+	// ASSERT
+	assert.Equal(t, result1, result2)
+}
+
+func TestTreeHasher_changed(t *testing.T) {
+
+	// ARRANGE
+	temp1 := t.TempDir()
+	temp2 := t.TempDir()
+	setupFiles(t, temp1)
+	setupFiles(t, temp2)
+
+	// 深い階層のファイルの内容を変更
+	setupFile(t, filepath.Join(temp2, "a", "xxx"), "x.txt", "changed", "")
+
+	// ACT & ASSERT
+	assert.NotEqual(t, treeHash(t, temp1), treeHash(t, temp2))
+	assert.NotEqual(t, treeHash(t, filepath.Join(temp1, "a")), treeHash(t, filepath.Join(temp2, "a")))
+	assert.NotEqual(t, treeHash(t, filepath.Join(temp1, "a", "xxx")), treeHash(t, filepath.Join(temp2, "a", "xxx")))
+
+	// 変更の無いディレクトリは同じ
+	assert.Equal(t, treeHash(t, filepath.Join(temp1, "x")), treeHash(t, filepath.Join(temp2, "x")))
+}
+
+func TestTreeHasher_renamed(t *testing.T) {
+
+	// ARRANGE
+	temp1 := t.TempDir()
+	temp2 := t.TempDir()
+	setupFiles(t, temp1)
+	setupFiles(t, temp2)
+
+	require.NoError(t, os.Rename(filepath.Join(temp2, "a", "a.txt"), filepath.Join(temp2, "a", "c.txt")))
+
+	// ACT & ASSERT
+	assert.NotEqual(t, treeHash(t, temp1), treeHash(t, temp2))
+}
+
+func TestTreeHasher_emptyDirAdded(t *testing.T) {
+
+	// ARRANGE
+	temp1 := t.TempDir()
+	temp2 := t.TempDir()
+	setupFiles(t, temp1)
+	setupFiles(t, temp2)
+
+	setupDir(t, filepath.Join(temp2, "x", "y", "empty"))
+
+	// ACT & ASSERT
+	assert.NotEqual(t, treeHash(t, temp1), treeHash(t, temp2))
+}
+
+func TestTreeHashColumn_cache(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFiles(t, temp)
+
+	column := TreeHashColumn()
+	dirInfo, err := os.Stat(filepath.Join(temp, "a"))
+	require.NoError(t, err)
+	_, err = column.Value(&Entry{Path: filepath.Join(temp, "a"), BaseDir: temp, Info: dirInfo})
+	require.NoError(t, err)
+
+	filePath := filepath.Join(temp, "a", "b.txt")
+	fileInfo, err := os.Stat(filePath)
+	require.NoError(t, err)
+
+	// ディレクトリの計算時に子孫のハッシュも求めているので、ファイルを読み直さない
+	require.NoError(t, os.Remove(filePath))
+
+	// ACT
+	result, err := column.Value(&Entry{Path: filePath, BaseDir: temp, Info: fileInfo})
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "fc11d6f28e59d3cc33c0b14ceb644bf0902ebd63d61218dffe9e7dac7c254542", result)
+}
+
+func TestTreeHasher_cacheEvicted(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFiles(t, temp)
+
+	hasher := newTreeHasher()
+	value := func(path string) string {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		result, err := hasher.value(t.Context(), path, info)
+		require.NoError(t, err)
+		return result
+	}
+
+	// ACT
+	// --level 1 で走査した場合と同じ順で、配下のエントリには到達しない
+	value(filepath.Join(temp, "1.txt"))
+	value(filepath.Join(temp, "a"))
+	cachedInA := len(hasher.cache)
+	value(filepath.Join(temp, "x"))
+
+	// ASSERT
+	// a の配下は a と a/xxx の子要素
+	assert.Equal(t, 2, cachedInA)
+	// 走査が a の外に出たので、a の配下は破棄されている
+	assert.Len(t, hasher.cache, 3)
+	assert.Contains(t, hasher.cache, filepath.Join(temp, "x"))
+	assert.Contains(t, hasher.cache, filepath.Join(temp, "x", "y"))
+	assert.Contains(t, hasher.cache, filepath.Join(temp, "x", "y", "z"))
+	assert.Equal(t, filepath.Join(temp, "x"), hasher.cacheRoot)
+}
+
+func TestTreeHasher_cacheSkipped(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFiles(t, temp)
+
+	hasher := newTreeHasher()
+	value := func(path string) string {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		result, err := hasher.value(t.Context(), path, info)
+		require.NoError(t, err)
+		return result
+	}
+
+	// ACT
+	value(filepath.Join(temp, "a"))
+	// a/a.txt、a/b.txt には到達せずに a/xxx に進んだ
+	xxx := value(filepath.Join(temp, "a", "xxx"))
+
+	// ASSERT
+	// a の子要素は全て到達済みか対象外になったので破棄され、a/xxx の子要素のみ残る
+	assert.Len(t, hasher.cache, 1)
+	assert.Contains(t, hasher.cache, filepath.Join(temp, "a", "xxx"))
+	assert.Equal(t, treeHash(t, filepath.Join(temp, "a", "xxx")), xxx)
+}
+
+func treeHash(t *testing.T, dir string) string {
+
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+
+	result, err := TreeHashColumn().Value(&Entry{Path: dir, BaseDir: filepath.Dir(dir), Info: info})
+	require.NoError(t, err)
+
+	return result
+}
+
+func TestTreeHashColumn_canceled(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFile(t, filepath.Join(temp, "a"), "a.txt", "A", "")
+	info, err := os.Stat(filepath.Join(temp, "a"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	// ACT
+	_, err = TreeHashColumn().Value(&Entry{Path: filepath.Join(temp, "a"), BaseDir: temp, Info: info, ctx: ctx})
+
+	// ASSERT
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,569 @@
+// Package filist ディレクトリ配下のファイルを走査し、パスやサイズ、ハッシュなどの列を計算する
+package filist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Entry 走査で見つかったファイル、ディレクトリ
+type Entry struct {
+	// パス (アーカイブ内のエントリは、アーカイブのパスに続けた仮想的なパス)
+	Path string
+	// 相対パスの基準となるディレクトリ
+	BaseDir string
+	// ルートからの階層 (ベースディレクトリの外のパスは階層が無いため -1)
+	Depth int
+	// 走査のルート
+	Root Root
+	Info fs.FileInfo
+
+	ctx context.Context
+}
+
+// Context 走査のコンテキスト (内容の読み込みなどを、走査と合わせて中断するため)
+func (e *Entry) Context() context.Context {
+
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// Root 走査のルート (指定されたディレクトリ、ファイル、またはパス一覧のベースディレクトリ)
+type Root struct {
+	// 指定されたままの名前
+	Name    string
+	AbsPath string
+}
+
+// WalkFunc 見つかったエントリごとに呼ばれる (エラーを返すと走査を中止する)
+type WalkFunc func(entry *Entry) error
+
+// SkipEntry WalkFunc がエントリを出力せずに読み飛ばしたことを示す (走査は続ける)
+// 読み飛ばしたエントリは、ファイル数の上限の対象に数えない
+var SkipEntry = errors.New("skip this entry")
+
+// Walker 走査の条件
+type Walker struct {
+	// ディレクトリも対象にする
+	IncludeDirectories bool
+	// ファイルを対象外にする
+	ExcludeFiles bool
+	// 走査するディレクトリ自体も対象にする (階層は0)
+	IncludeRoot bool
+	// 走査する階層 (0は無制限)
+	Level int
+	// 対象にする最小の階層 (それより浅いエントリは対象外だが、配下は走査する)
+	MinLevel int
+	// 隠しファイル、隠しディレクトリを対象外にする
+	NoHidden bool
+	// 隠しファイル、隠しディレクトリとその配下のみを対象にする
+	HiddenOnly bool
+	// 別のファイルシステムのディレクトリ配下は走査しない
+	OneFileSystem bool
+	// 対象外にするマウントポイント
+	ExcludedMounts map[string]bool
+	// アーカイブをディレクトリとして走査する
+	Archives bool
+	// アーカイブ内のアーカイブも走査する
+	NestedArchives bool
+	// 相対パスの先頭にルートのディレクトリ名を付ける
+	PrefixRoot bool
+	// 引数のファイル、パス一覧の相対パスの基準 (空の場合は、ファイルのあるディレクトリ、カレントディレクトリ)
+	BaseDir string
+	// 見つかったエントリを数える進捗 (nilの場合は数えない)
+	Progress *Progress
+	// 走査と読み込みの速度の上限 (nilの場合は無制限)
+	Throttle *Throttle
+}
+
+var errStopIteration = errors.New("stop iteration")
+
+// Entries 走査したエントリを順に返す
+func (w *Walker) Entries(ctx context.Context, roots []string) iter.Seq2[*Entry, error] {
+
+	return func(yield func(*Entry, error) bool) {
+
+		err := w.Walk(ctx, roots, func(entry *Entry) error {
+			if !yield(entry, nil) {
+				return errStopIteration
+			}
+			return nil
+		})
+
+		if err != nil && err != errStopIteration {
+			yield(nil, err)
+		}
+	}
+}
+
+// Walk 指定のディレクトリ配下、またはファイルを走査する
+// コンテキストがキャンセルされた場合は、そのエラーを返して走査を中止する
+func (w *Walker) Walk(ctx context.Context, roots []string, fn WalkFunc) error {
+
+	ctx = w.Throttle.withContext(ctx)
+
+	for _, name := range roots {
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+
+		root, err := newRoot(name)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			err = w.walkFS(ctx, newOSFS(root.AbsPath), root.AbsPath, root, fn)
+		} else {
+			err = w.walkFile(ctx, root, fn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WalkFS fs.FS の配下を走査する (dir は表示上のディレクトリのパス)
+func (w *Walker) WalkFS(ctx context.Context, fsys fs.FS, dir string, fn WalkFunc) error {
+	return w.walkFS(w.Throttle.withContext(ctx), fsys, dir, Root{Name: dir, AbsPath: dir}, fn)
+}
+
+// WalkPaths 走査せずに指定のパスのみを対象にする
+// 相対パスはベースディレクトリ(指定が無ければカレントディレクトリ)からのパスとみなす
+func (w *Walker) WalkPaths(ctx context.Context, paths []string, fn WalkFunc) error {
+
+	ctx = w.Throttle.withContext(ctx)
+
+	baseDir := w.BaseDir
+	if baseDir == "" {
+		baseDir = "."
+	}
+
+	root, err := newRoot(baseDir)
+	if err != nil {
+		return err
+	}
+
+	relBaseDir := root.AbsPath
+	if w.PrefixRoot {
+		relBaseDir = filepath.Dir(root.AbsPath)
+	}
+
+	for _, path := range paths {
+
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root.AbsPath, path)
+		}
+
+		if err := w.walkPath(ctx, root, root.AbsPath, relBaseDir, filepath.Clean(path), fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newRoot(name string) (Root, error) {
+
+	absPath, err := filepath.Abs(name)
+	if err != nil {
+		return Root{}, err
+	}
+
+	return Root{Name: name, AbsPath: absPath}, nil
+}
+
+// walkFile 引数で指定されたファイル
+// 相対パスはベースディレクトリの指定が無ければ、ファイルのあるディレクトリからのパスとする
+func (w *Walker) walkFile(ctx context.Context, root Root, fn WalkFunc) error {
+
+	baseDir := filepath.Dir(root.AbsPath)
+	if w.BaseDir != "" {
+		var err error
+		baseDir, err = filepath.Abs(w.BaseDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.walkPath(ctx, root, baseDir, baseDir, root.AbsPath, fn)
+}
+
+// walkPath 走査せずに指定のパスのみ (rootDir は階層の基準となるディレクトリ)
+func (w *Walker) walkPath(ctx context.Context, root Root, rootDir string, baseDir string, path string, fn WalkFunc) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		if !w.IncludeDirectories {
+			return nil
+		}
+	} else {
+		if w.ExcludeFiles {
+			return nil
+		}
+	}
+
+	depth, err := getDepth(rootDir, path)
+	if err != nil {
+		return err
+	}
+
+	// 走査する場合と同じく、階層と隠しファイルの条件を適用する
+	if w.Level != 0 || w.MinLevel != 0 {
+		if depth < 0 {
+			return fmt.Errorf("level cannot be determined for a path outside the base directory: %s", path)
+		}
+		if depth < w.MinLevel || (w.Level != 0 && depth > w.Level) {
+			return nil
+		}
+	}
+
+	if w.NoHidden || w.HiddenOnly {
+		// ベースディレクトリからのパスに隠しファイル、隠しディレクトリが含まれるか
+		hidden, err := inHiddenPath(rootDir, path)
+		if err != nil {
+			return err
+		}
+		if (w.NoHidden && hidden) || (w.HiddenOnly && !hidden) {
+			return nil
+		}
+	}
+
+	w.Progress.addEntry(path, info)
+
+	return w.call(ctx, fn, &Entry{
+		Path:    path,
+		BaseDir: baseDir,
+		Depth:   depth,
+		Root:    root,
+		Info:    info,
+		ctx:     ctx,
+	})
+}
+
+// call エントリを渡して fn を呼び出し、読み飛ばされなかった場合はファイル数の上限を超えないように待つ
+func (w *Walker) call(ctx context.Context, fn WalkFunc, entry *Entry) error {
+
+	err := fn(entry)
+	if err == SkipEntry {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return w.Throttle.waitFile(ctx, entry.Info)
+}
+
+func (w *Walker) walkFS(ctx context.Context, fsys fs.FS, dir string, root Root, fn WalkFunc) error {
+
+	relBaseDir := dir
+	if w.PrefixRoot {
+		// 親ディレクトリからの相対パスにすることで、ルートのディレクトリ名を先頭に付ける
+		relBaseDir = filepath.Dir(dir)
+	}
+
+	walker := &dirWalker{
+		Walker:     w,
+		ctx:        ctx,
+		fn:         fn,
+		root:       root,
+		absDir:     dir,
+		relBaseDir: relBaseDir,
+	}
+
+	if w.OneFileSystem {
+		info, err := fs.Stat(fsys, ".")
+		if err != nil {
+			return err
+		}
+		walker.rootDevice, _ = deviceID(info)
+	}
+
+	if w.IncludeRoot && w.MinLevel == 0 && !w.HiddenOnly {
+		info, err := fs.Stat(fsys, ".")
+		if err != nil {
+			return err
+		}
+		// 配下と同じく、fs.FS から内容や一覧を取得できる情報にする
+		if err := walker.emit(dir, 0, newFSFileInfo(fsys, ".", info)); err != nil {
+			return err
+		}
+	}
+
+	return walker.walk(fsys, dir)
+}
+
+// dirWalker ディレクトリ配下の走査中の状態
+type dirWalker struct {
+	*Walker
+	ctx        context.Context
+	fn         WalkFunc
+	root       Root
+	absDir     string
+	relBaseDir string
+	rootDevice uint64
+	// 走査中のアーカイブの階層 (アーカイブ内のアーカイブかの判定用)
+	archiveDepth int
+}
+
+// walk fs.FS の配下を走査する (dirPath は fs.FS のルートの表示上のパス)
+func (w *dirWalker) walk(fsys fs.FS, dirPath string) error {
+
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+
+		if name == "." {
+			return nil
+		}
+
+		entry := &fsDirEntry{DirEntry: d, fsys: fsys, name: name, inArchive: w.archiveDepth > 0}
+		return w.visit(filepath.Join(dirPath, filepath.FromSlash(name)), entry)
+	})
+}
+
+func (w *dirWalker) visit(path string, d fs.DirEntry) error {
+
+	if w.NoHidden && isHidden(d.Name()) {
+		if d.IsDir() {
+			// 隠しディレクトリの配下は見ない
+			return filepath.SkipDir
+		}
+		return nil
+	}
+
+	depth, err := getDepth(w.absDir, path)
+	if err != nil {
+		return err
+	}
+
+	// 指定レベル未満は対象外 (配下は見る)
+	printable := depth >= w.MinLevel
+
+	if w.HiddenOnly && printable {
+		// 隠しディレクトリの配下かどうかを見るため、配下は見る
+		hidden, err := inHiddenPath(w.absDir, path)
+		if err != nil {
+			return err
+		}
+		printable = hidden
+	}
+
+	if d.IsDir() {
+		if w.ExcludedMounts[path] {
+			// 除外対象のファイルシステムは、マウントポイント自体も対象外
+			return filepath.SkipDir
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if w.IncludeDirectories && printable {
+			if err := w.emit(path, depth, info); err != nil {
+				return err
+			}
+		}
+
+		if w.OneFileSystem {
+			if device, ok := deviceID(info); ok && device != w.rootDevice {
+				// 別のファイルシステムは、マウントポイントは対象とするが配下は見ない (find -xdev と同じ)
+				return filepath.SkipDir
+			}
+		}
+
+		if w.Level != 0 && depth >= w.Level {
+			// 指定レベル以上になったら、そのディレクトリ配下は見ない
+			return filepath.SkipDir
+		}
+
+	} else if kind := w.archiveKind(d); kind != archiveNone {
+		return w.visitArchive(path, d, kind, depth, printable)
+
+	} else {
+		if !w.ExcludeFiles && printable {
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			return w.emit(path, depth, info)
+		}
+	}
+
+	return nil
+}
+
+func (w *dirWalker) emit(path string, depth int, info fs.FileInfo) error {
+
+	w.Progress.addEntry(path, info)
+
+	return w.call(w.ctx, w.fn, &Entry{
+		Path:    path,
+		BaseDir: w.relBaseDir,
+		Depth:   depth,
+		Root:    w.root,
+		Info:    info,
+		ctx:     w.ctx,
+	})
+}
+
+// archiveKind ディレクトリとして扱うアーカイブであれば、その種類を返す
+func (w *dirWalker) archiveKind(d fs.DirEntry) archiveKind {
+
+	if !w.Archives || !d.Type().IsRegular() {
+		return archiveNone
+	}
+
+	if w.archiveDepth > 0 && !w.NestedArchives {
+		return archiveNone
+	}
+
+	return detectArchiveKind(d.Name())
+}
+
+// visitArchive アーカイブをディレクトリとして扱い、その中身を走査する
+func (w *dirWalker) visitArchive(path string, d fs.DirEntry, kind archiveKind, depth int, printable bool) error {
+
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+
+	open := func() (io.ReadCloser, error) {
+		return openFile(path, info)
+	}
+
+	// アーカイブの中身の読み込みにも、速度の上限を適用する
+	fsys, closeArchive, err := openArchive(kind, func() (io.ReadCloser, error) {
+		return openContent(w.ctx, path, info)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	defer closeArchive()
+
+	archiveInfo := &fsFileInfo{
+		FileInfo: info,
+		open:     open,
+		fsys:     fsys,
+		name:     ".",
+		archive:  true,
+	}
+
+	if w.IncludeDirectories && printable {
+		if err := w.emit(path, depth, archiveInfo); err != nil {
+			return err
+		}
+	}
+
+	if w.Level != 0 && depth >= w.Level {
+		return nil
+	}
+
+	w.archiveDepth++
+	defer func() { w.archiveDepth-- }()
+
+	return w.walk(fsys, path)
+}
+
+// ReadPaths 改行区切りまたはNUL区切りのパス一覧を読み込む
+// NULが含まれていればNUL区切り(find -print0 や git ls-files -z の出力)とみなす
+func ReadPaths(r io.Reader) ([]string, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []string
+	if strings.ContainsRune(string(data), 0) {
+		items = strings.Split(string(data), "\x00")
+	} else {
+		items = strings.Split(string(data), "\n")
+		for i, item := range items {
+			items[i] = strings.TrimSuffix(item, "\r")
+		}
+	}
+
+	var paths []string
+	for _, item := range items {
+		if item != "" {
+			paths = append(paths, item)
+		}
+	}
+
+	return paths, nil
+}
+
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".") && name != "." && name != ".."
+}
+
+// inHiddenPath ベースディレクトリからのパスに隠しファイル、隠しディレクトリが含まれるか
+func inHiddenPath(basePath string, path string) (bool, error) {
+
+	relPath, err := filepath.Rel(basePath, path)
+	if err != nil {
+		return false, err
+	}
+
+	for _, name := range strings.Split(relPath, string(filepath.Separator)) {
+		if isHidden(name) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func getDepth(basePath string, path string) (int, error) {
+
+	relPath, err := filepath.Rel(basePath, path)
+	if err != nil {
+		return 0, err
+	}
+
+	if relPath == "." {
+		return 0, nil
+	}
+	if relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		// ベースディレクトリの外は階層が無い
+		return -1, nil
+	}
+
+	return len(strings.Split(relPath, string(filepath.Separator))), nil
+}
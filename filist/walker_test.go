@@ -0,0 +1,552 @@
+package filist
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalker_Walk(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFiles(t, temp)
+
+	walker := &Walker{}
+
+	var paths []string
+
+	// ACT
+	err := walker.Walk(t.Context(), []string{temp}, func(entry *Entry) error {
+		paths = append(paths, entry.Path)
+		assert.Equal(t, temp, entry.BaseDir)
+		assert.Equal(t, Root{Name: temp, AbsPath: temp}, entry.Root)
+		return nil
+	})
+
+	// ASSERT
+	require.NoError(t, err)
+
+	expected := []string{
+		filepath.Join(temp, "1.txt"),
+		filepath.Join(temp, "a", "a.txt"),
+		filepath.Join(temp, "a", "b.txt"),
+		filepath.Join(temp, "a", "xxx", "x.txt"),
+		filepath.Join(temp, "x", "y", "z", "テスト.txt"),
+	}
+	assert.Equal(t, expected, paths)
+}
+
+func TestWalker_Walk_stop(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFiles(t, temp)
+
+	walker := &Walker{}
+	stop := errors.New("stop")
+
+	count := 0
+
+	// ACT
+	err := walker.Walk(t.Context(), []string{temp}, func(entry *Entry) error {
+		count++
+		if count == 2 {
+			return stop
+		}
+		return nil
+	})
+
+	// ASSERT
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestWalker_Entries(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFiles(t, temp)
+
+	walker := &Walker{IncludeDirectories: true, Level: 1}
+
+	var names []string
+
+	// ACT
+	for entry, err := range walker.Entries(t.Context(), []string{temp}) {
+		require.NoError(t, err)
+		names = append(names, entry.Info.Name())
+	}
+
+	// ASSERT
+	assert.Equal(t, []string{"1.txt", "a", "x"}, names)
+}
+
+func TestWalker_Entries_break(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFiles(t, temp)
+
+	walker := &Walker{}
+
+	count := 0
+
+	// ACT
+	for _, err := range walker.Entries(t.Context(), []string{temp}) {
+		require.NoError(t, err)
+		count++
+		if count == 2 {
+			break
+		}
+	}
+
+	// ASSERT
+	assert.Equal(t, 2, count)
+}
+
+func TestWalker_Entries_error(t *testing.T) {
+
+	// ARRANGE
+	walker := &Walker{}
+
+	var errs []error
+
+	// ACT
+	for entry, err := range walker.Entries(t.Context(), []string{filepath.Join(t.TempDir(), "not-found")}) {
+		assert.Nil(t, entry)
+		errs = append(errs, err)
+	}
+
+	// ASSERT
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], fs.ErrNotExist)
+}
+
+func TestWalker_ExcludedMounts(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	walker := &Walker{
+		IncludeDirectories: true,
+		ExcludedMounts: map[string]bool{
+			filepath.Join(temp, "a", "xxx"): true,
+			filepath.Join(temp, "x"):        true,
+		},
+	}
+
+	// ACT
+	result := walkAndFormat(t, []Column{RelPathColumn()}, func(fn WalkFunc) error {
+		return walker.Walk(t.Context(), []string{temp}, fn)
+	})
+
+	// ASSERT
+	expected := allLines(
+		line("1.txt"),
+		line("a"+string(filepath.Separator)),
+		line(filepath.Join("a", "a.txt")),
+		line(filepath.Join("a", "b.txt")),
+	)
+	assert.Equal(t, expected, result)
+}
+
+func TestWalker_WalkPaths(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFiles(t, temp)
+
+	walker := &Walker{BaseDir: temp}
+
+	// ACT
+	result := walkAndFormat(t, []Column{RelPathColumn(), DepthColumn()}, func(fn WalkFunc) error {
+		return walker.WalkPaths(t.Context(), []string{"1.txt", filepath.Join("a", "xxx", "x.txt"), "a"}, fn)
+	})
+
+	// ASSERT
+	expected := allLines(
+		line("1.txt", "1"),
+		line(filepath.Join("a", "xxx", "x.txt"), "3"),
+	)
+	assert.Equal(t, expected, result)
+}
+
+func TestWalker_WalkPaths_Level(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFiles(t, temp)
+
+	walker := &Walker{BaseDir: temp, MinLevel: 2, Level: 2}
+
+	// ACT
+	result := walkAndFormat(t, []Column{RelPathColumn(), DepthColumn()}, func(fn WalkFunc) error {
+		return walker.WalkPaths(t.Context(), []string{"1.txt", filepath.Join("a", "a.txt"), filepath.Join("a", "xxx", "x.txt")}, fn)
+	})
+
+	// ASSERT
+	expected := allLines(
+		line(filepath.Join("a", "a.txt"), "2"),
+	)
+	assert.Equal(t, expected, result)
+}
+
+func TestWalker_WalkPaths_Hidden(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFiles(t, temp)
+	setupFile(t, filepath.Join(temp, ".h"), "3.txt", "", "")
+	setupFile(t, temp, ".2.txt", "", "")
+
+	paths := []string{"1.txt", ".2.txt", filepath.Join(".h", "3.txt")}
+
+	tests := []struct {
+		name     string
+		walker   *Walker
+		expected string
+	}{
+		{
+			"no hidden",
+			&Walker{BaseDir: temp, NoHidden: true},
+			allLines(line("1.txt")),
+		},
+		{
+			"hidden only",
+			&Walker{BaseDir: temp, HiddenOnly: true},
+			allLines(line(".2.txt"), line(filepath.Join(".h", "3.txt"))),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ACT
+			result := walkAndFormat(t, []Column{RelPathColumn()}, func(fn WalkFunc) error {
+				return tt.walker.WalkPaths(t.Context(), paths, fn)
+			})
+
+			// ASSERT
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestWalker_WalkPaths_OutsideBase(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFiles(t, temp)
+
+	baseDir := filepath.Join(temp, "a")
+	path := filepath.Join(temp, "1.txt")
+
+	// ACT
+	result := walkAndFormat(t, []Column{RelPathColumn(), DepthColumn()}, func(fn WalkFunc) error {
+		return (&Walker{BaseDir: baseDir}).WalkPaths(t.Context(), []string{path}, fn)
+	})
+
+	// ASSERT
+	// ベースディレクトリの外は階層が無い
+	expected := allLines(
+		line(filepath.Join("..", "1.txt"), ""),
+	)
+	assert.Equal(t, expected, result)
+
+	// 階層の条件は判断できない
+	err := (&Walker{BaseDir: baseDir, MinLevel: 1}).WalkPaths(t.Context(), []string{path}, func(entry *Entry) error {
+		return nil
+	})
+	assert.EqualError(t, err, "level cannot be determined for a path outside the base directory: "+path)
+}
+
+func TestWalker_WalkFS(t *testing.T) {
+
+	// ARRANGE
+	fsys := fstest.MapFS{
+		"1.txt":       {Data: []byte("x"), ModTime: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		"a/a.txt":     {Data: []byte("A\nB\n")},
+		"a/xxx/x.txt": {Data: []byte("")},
+		"b":           {Mode: fs.ModeDir},
+	}
+
+	scanner := NewContentScanner()
+	md5Column, err := scanner.HashColumn("md5")
+	require.NoError(t, err)
+
+	columns := []Column{
+		RelPathColumn(),
+		SizeColumn(SizeFormatBytes, 0),
+		MtimeColumn(),
+		scanner.CountColumn("lines"),
+		md5Column,
+	}
+
+	dir := filepath.Join(string(filepath.Separator), "virtual")
+	walker := &Walker{IncludeDirectories: true}
+
+	// ACT
+	result := walkAndFormat(t, columns, func(fn WalkFunc) error {
+		return walker.WalkFS(t.Context(), fsys, dir, fn)
+	})
+
+	// ASSERT
+	expected := allLines(
+		line("1.txt", "1", "2020-01-01T00:00:00.000000+00:00", "1", "9dd4e461268c8034f5c8564e155c67a6"),
+		line("a"+string(filepath.Separator), "", "", "", ""),
+		line(filepath.Join("a", "a.txt"), "4", "0001-01-01T00:00:00.000000+00:00", "2", "d09f55e3d6d2265b7507cc6027071d0a"),
+		line(filepath.Join("a", "xxx")+string(filepath.Separator), "", "", "", ""),
+		line(filepath.Join("a", "xxx", "x.txt"), "0", "0001-01-01T00:00:00.000000+00:00", "0", "d41d8cd98f00b204e9800998ecf8427e"),
+		line("b"+string(filepath.Separator), "", "", "", ""),
+	)
+	assert.Equal(t, expected, result)
+}
+
+func TestWalker_WalkFS_Level(t *testing.T) {
+
+	// ARRANGE
+	fsys := fstest.MapFS{
+		"1.txt":       {},
+		"a/a.txt":     {},
+		"a/xxx/x.txt": {},
+	}
+
+	dir := filepath.Join(string(filepath.Separator), "virtual")
+	walker := &Walker{MinLevel: 2, Level: 2}
+
+	// ACT
+	result := walkAndFormat(t, []Column{RelPathColumn(), DepthColumn()}, func(fn WalkFunc) error {
+		return walker.WalkFS(t.Context(), fsys, dir, fn)
+	})
+
+	// ASSERT
+	expected := allLines(
+		line(filepath.Join("a", "a.txt"), "2"),
+	)
+	assert.Equal(t, expected, result)
+}
+
+func TestWalker_WalkFS_TreeHash(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are different on this platform")
+	}
+
+	// ARRANGE
+	// 同じ内容をOSのファイルシステムとメモリ上に用意
+	temp := t.TempDir()
+	setupFile(t, filepath.Join(temp, "a"), "a.txt", "A", "")
+	setupFile(t, filepath.Join(temp, "a"), "b.txt", "BB", "")
+	require.NoError(t, os.Chmod(filepath.Join(temp, "a", "a.txt"), 0644))
+	require.NoError(t, os.Chmod(filepath.Join(temp, "a", "b.txt"), 0644))
+	require.NoError(t, os.Chmod(filepath.Join(temp, "a"), 0755))
+
+	mapFS := fstest.MapFS{
+		"a":       {Mode: fs.ModeDir | 0755},
+		"a/a.txt": {Data: []byte("A"), Mode: 0644},
+		"a/b.txt": {Data: []byte("BB"), Mode: 0644},
+	}
+
+	walker := &Walker{IncludeDirectories: true, IncludeRoot: true}
+
+	walkTreeHash := func(fsys fs.FS) string {
+		return walkAndFormat(t, []Column{RelPathColumn(), TreeHashColumn()}, func(fn WalkFunc) error {
+			return walker.WalkFS(t.Context(), fsys, temp, fn)
+		})
+	}
+
+	// ACT
+	osResult := walkTreeHash(newOSFS(temp))
+	mapResult := walkTreeHash(mapFS)
+
+	// ASSERT
+	assert.Equal(t, osResult, mapResult)
+	assert.Contains(t, osResult, line("a"+string(filepath.Separator), treeHash(t, filepath.Join(temp, "a"))))
+	// ルート自体のハッシュも、メモリ上の fs.FS から計算できる
+	assert.True(t, strings.HasPrefix(mapResult, line("."+string(filepath.Separator), treeHash(t, temp))))
+}
+
+func TestWalker_WalkFS_Archives(t *testing.T) {
+
+	// ARRANGE
+	fsys := fstest.MapFS{
+		"a.zip": {Data: createZip(t, map[string]string{
+			"inner.txt": "I",
+		})},
+	}
+
+	dir := filepath.Join(string(filepath.Separator), "virtual")
+	walker := &Walker{Archives: true}
+
+	// ACT
+	result := walkAndFormat(t, []Column{RelPathColumn(), SizeColumn(SizeFormatBytes, 0)}, func(fn WalkFunc) error {
+		return walker.WalkFS(t.Context(), fsys, dir, fn)
+	})
+
+	// ASSERT
+	expected := allLines(
+		line(filepath.Join("a.zip", "inner.txt"), "1"),
+	)
+	assert.Equal(t, expected, result)
+}
+
+func TestOSFS(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFile(t, filepath.Join(temp, "a"), "a.txt", "A", "")
+	setupFile(t, filepath.Join(temp, "a"), "b.txt", "BB", "")
+
+	// ACT & ASSERT
+	assert.NoError(t, fstest.TestFS(newOSFS(temp), "a/a.txt", "a/b.txt"))
+}
+
+func TestOSFS_ReadLink(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links are not supported on this platform")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFile(t, temp, "a.txt", "A", "")
+	require.NoError(t, os.Symlink("a.txt", filepath.Join(temp, "link")))
+
+	fsys := newOSFS(temp)
+
+	// ACT
+	target, err := fsys.ReadLink("link")
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt", target)
+}
+
+func TestOSFS_InvalidPath(t *testing.T) {
+
+	// ARRANGE
+	fsys := newOSFS(t.TempDir())
+
+	// ACT
+	_, err := fsys.Open("../a.txt")
+
+	// ASSERT
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func TestReadPaths(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"newline", "a.txt\nb/c.txt\n", []string{"a.txt", "b/c.txt"}},
+		{"crlf", "a.txt\r\nb.txt", []string{"a.txt", "b.txt"}},
+		{"nul", "a\nb.txt\x00c.txt\x00", []string{"a\nb.txt", "c.txt"}},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ACT
+			result, err := ReadPaths(strings.NewReader(tt.input))
+
+			// ASSERT
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func walkAndFormat(t *testing.T, columns []Column, walk func(fn WalkFunc) error) string {
+
+	out := new(bytes.Buffer)
+	formatter := &TSVFormatter{}
+
+	err := walk(func(entry *Entry) error {
+		values, err := Values(entry, columns)
+		if err != nil {
+			return err
+		}
+		return formatter.Format(out, columns, values)
+	})
+	require.NoError(t, err)
+
+	return out.String()
+}
+
+func TestWalker_Walk_canceled(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFiles(t, temp)
+
+	walker := &Walker{}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	var paths []string
+
+	// ACT
+	err := walker.Walk(ctx, []string{temp, temp}, func(entry *Entry) error {
+		paths = append(paths, entry.Path)
+		assert.Equal(t, ctx, entry.Context())
+		cancel()
+		return nil
+	})
+
+	// ASSERT
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{filepath.Join(temp, "1.txt")}, paths)
+}
+
+func TestWalker_WalkPaths_canceled(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFiles(t, temp)
+
+	walker := &Walker{BaseDir: temp}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	called := false
+
+	// ACT
+	err := walker.WalkPaths(ctx, []string{"1.txt"}, func(entry *Entry) error {
+		called = true
+		return nil
+	})
+
+	// ASSERT
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, called)
+}
+
+func TestEntry_Context(t *testing.T) {
+
+	// ARRANGE
+	entry := &Entry{}
+
+	// ACT
+	ctx := entry.Context()
+
+	// ASSERT
+	assert.Equal(t, context.Background(), ctx)
+}
@@ -1,18 +1,22 @@
 package main
 
 import (
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
-	"hash"
 	"io"
-	"io/fs"
+	"math"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/onozaty/filist/filist"
 	flag "github.com/spf13/pflag"
 )
 
@@ -21,19 +25,14 @@ var (
 	Commit  = "dev"
 )
 
-// Option 表示のオプション
-type Option struct {
-	includeDirectories bool
-	excludeFiles       bool
-	level              int
-	columns            []func(string, string, os.FileInfo) (string, error)
-}
-
 const (
 	OK int = 0
 	NG int = 1
 )
 
+// 中断の報告の出力先 (一覧の出力に混ざって、追記した時に行が壊れないように標準エラーに出す)
+var errorOut io.Writer = os.Stderr
+
 func main() {
 	exitCode := run(os.Args[1:], os.Stdout)
 	os.Exit(exitCode)
@@ -41,31 +40,123 @@ func main() {
 
 func run(arguments []string, out io.Writer) int {
 
+	if len(arguments) > 0 {
+		switch arguments[0] {
+		case "keygen":
+			return runKeygen(arguments[1:], out)
+		case "verify-sig":
+			return runVerifySig(arguments[1:], out)
+		}
+	}
+
 	var help bool
 	var printRelPath bool
 	var printAbsPath bool
 	var includeDirectories bool
 	var excludeFiles bool
+	var includeRoot bool
 	var level int
+	var minLevel int
+	var noHidden bool
+	var hiddenOnly bool
+	var oneFileSystem bool
+	var excludeFsTypes []string
+	var archives bool
+	var nestedArchives bool
+	var human bool
+	var si bool
+	var precision int
+	var hashNames []string
+	var hmacNames []string
+	var keyFile string
+	var signKeyFile string
+	var verifyFile string
+	var execColumns []string
+	var execTimeout time.Duration
+	var execJobs int
+	var columnsSpec string
+	var listColumns bool
+	var profile string
+	var noConfig bool
+	var showProgress bool
+	var timeout time.Duration
+	var checkpointFile string
+	var resume bool
+	var maxReadRate string
+	var maxFilesPerSec float64
+	var dropCache bool
+	var fromFile string
+	var nullTerminated bool
+	var escape bool
+	var prefixRoot bool
+	var baseDir string
 
 	flagSet := flag.NewFlagSet("filist", flag.ContinueOnError)
 
-	flagSet.BoolVarP(&printRelPath, "rel", "r", false, "Print relative path (If neither 'rel' nor 'abs' is specified, 'rel' will be printed first column.)")
+	flagSet.BoolVarP(&printRelPath, "rel", "r", false, "Print relative path (If neither 'rel' nor 'abs' nor 'columns' is specified, 'rel' will be printed first column.)")
 	flagSet.BoolVarP(&printAbsPath, "abs", "a", false, "Print absolute path")
+	flagSet.BoolP("root", "", false, "Print root (directory or file as specified in the arguments)")
+	flagSet.BoolP("root-abs", "", false, "Print absolute path of root")
+	flagSet.BoolVarP(&prefixRoot, "prefix-root", "", false, "Prefix relative path with the name of root directory")
 	flagSet.BoolP("size", "s", false, "Print file size")
+	flagSet.BoolVarP(&human, "human", "", false, "Print file size in human-readable format with IEC units (KiB, MiB, ...)")
+	flagSet.BoolVarP(&si, "si", "", false, "Print file size in human-readable format with SI units (kB, MB, ...)")
+	flagSet.IntVarP(&precision, "precision", "", 1, "Number of decimal places for human-readable size")
 	flagSet.BoolP("mtime", "m", false, "Print modification time")
+	flagSet.BoolP("depth", "", false, "Print directory level")
+	flagSet.BoolP("lines", "", false, "Print number of lines (LF and CRLF are each counted as one line break, '-' for binary files)")
+	flagSet.BoolP("words", "", false, "Print number of words ('-' for binary files)")
+	flagSet.BoolP("chars", "", false, "Print number of characters (CRLF is counted as one character, '-' for binary files)")
+	flagSet.BoolP("encoding", "", false, "Print text encoding (ASCII, UTF-8, UTF-8-BOM, UTF-16LE, UTF-16BE, invalid-UTF-8)")
+	flagSet.BoolP("eol", "", false, "Print line ending (LF, CRLF, CR, mixed, none)")
+	flagSet.BoolP("mime", "", false, "Print content type detected from the head of the file")
+	flagSet.BoolP("text-binary", "", false, "Print whether the file is text or binary")
 	flagSet.BoolP("md5", "M", false, "Print MD5 hash")
 	flagSet.BoolP("sha1", "S", false, "Print SHA-1 hash")
 	flagSet.BoolP("sha256", "", false, "Print SHA-256 hash")
+	flagSet.StringSliceVarP(&hashNames, "hash", "", nil, "Print hashes of the specified algorithms, comma separated ("+strings.Join(filist.HashAlgorithmNames(false), ", ")+")")
+	flagSet.StringSliceVarP(&hmacNames, "hmac", "", nil, "Print HMACs of the specified algorithms, comma separated ("+strings.Join(filist.HashAlgorithmNames(true), ", ")+")")
+	flagSet.StringVarP(&keyFile, "key-file", "", "", "Key file for HMAC")
+	flagSet.BoolP("tree-hash", "", false, "Print Merkle tree hash (SHA-256 of contents for files, hash of all children for directories)")
+	flagSet.StringArrayVarP(&execColumns, "exec-column", "", nil, "Print output of an external command as a column (NAME='command {}', {} is replaced with the file path)")
+	flagSet.DurationVarP(&execTimeout, "exec-timeout", "", 30*time.Second, "Timeout for each command of --exec-column (0 is unlimited)")
+	flagSet.IntVarP(&execJobs, "exec-jobs", "", 4, "Maximum number of commands of --exec-column to run at the same time")
+	flagSet.StringVarP(&columnsSpec, "columns", "", "", "Print the specified columns, comma separated, with an option after ':' (e.g. rel,size:human,mtime:unix,sha256:12)")
+	flagSet.BoolVarP(&listColumns, "list-columns", "", false, "List columns available for --columns and their options")
 	flagSet.BoolVarP(&includeDirectories, "include-dir", "", false, "Include directories")
 	flagSet.BoolVarP(&excludeFiles, "exclude-file", "", false, "Exclude files")
+	flagSet.BoolVarP(&includeRoot, "include-root", "", false, "Include the specified directories themselves (e.g. to print --tree-hash of the whole tree)")
 	flagSet.IntVarP(&level, "level", "l", 0, "Number of directory level (Default is unlimited)")
+	flagSet.BoolVarP(&noHidden, "no-hidden", "", false, "Exclude hidden files and directories (names starting with '.')")
+	flagSet.BoolVarP(&hiddenOnly, "hidden-only", "", false, "Print only hidden files and directories, and entries under hidden directories")
+	flagSet.BoolVarP(&oneFileSystem, "one-file-system", "", false, "Do not descend into directories on other file systems")
+	flagSet.StringSliceVarP(&excludeFsTypes, "exclude-fstype", "", nil, "Skip mount points of the specified file system types, comma separated (e.g. nfs,tmpfs,proc)")
+	flagSet.BoolVarP(&archives, "archives", "", false, "List contents of archives (.zip, .tar, .tar.gz, .tgz) as directories")
+	flagSet.BoolVarP(&nestedArchives, "nested-archives", "", false, "Also list contents of archives inside archives (requires --archives)")
+	flagSet.IntVarP(&minLevel, "min-level", "", 0, "Minimum directory level to print (Default is unlimited)")
+	flagSet.StringVarP(&fromFile, "from-file", "", "", "Read paths from the specified file instead of walking directories ('-' for stdin, newline or NUL delimited)")
+	flagSet.StringVarP(&baseDir, "base", "", "", "Base directory for relative paths of file arguments and --from-file (Default is the parent directory for file arguments, current directory for --from-file)")
+	flagSet.BoolVarP(&nullTerminated, "null", "0", false, "Terminate each line with NUL instead of newline")
+	flagSet.BoolVarP(&escape, "escape", "", false, "Escape control characters (\\n, \\t, \\xHH, ...) and backslashes in the output")
+	flagSet.StringVarP(&signKeyFile, "sign", "", "", "Append Ed25519 signature of the output with the specified private key file")
+	flagSet.StringVarP(&verifyFile, "verify", "", "", "Compare the listing with the specified manifest instead of printing it (specify the same flags and --key-file as when the manifest was created)")
+	flagSet.BoolVarP(&showProgress, "progress", "", false, "Print progress on stderr (only if stderr is a terminal)")
+	flagSet.DurationVarP(&timeout, "timeout", "", 0, "Stop listing after the specified duration (e.g. 30m, 0 is unlimited)")
+	flagSet.StringVarP(&checkpointFile, "checkpoint", "", "", "Record the printed entries to the specified file, to resume the listing with --resume")
+	flagSet.BoolVarP(&resume, "resume", "", false, "Skip the entries already printed according to --checkpoint, to append the rest to the previous output")
+	flagSet.StringVarP(&maxReadRate, "max-read-rate", "", "", "Limit the bytes read per second for content columns (e.g. 50M, units are K, M, G and T in 1024)")
+	flagSet.Float64VarP(&maxFilesPerSec, "max-files-per-sec", "", 0, "Limit the files listed per second (0 is unlimited)")
+	flagSet.BoolVarP(&dropCache, "drop-cache", "", false, "Advise the OS to drop the page cache of files after reading them (Linux only)")
+	flagSet.StringVarP(&profile, "profile", "", "", "Use the named profile in config files")
+	flagSet.BoolVarP(&noConfig, "no-config", "", false, "Do not read config files")
 	flagSet.BoolVarP(&help, "help", "h", false, "Help")
 
 	flagSet.SortFlags = false
 	flagSet.Usage = func() {
 		fmt.Fprintf(out, "filist v%s (%s)\n\n", Version, Commit)
-		fmt.Fprint(out, "Usage: filist [flags] directory ...\n\nFlags\n")
+		fmt.Fprint(out, "Usage: filist [flags] directory|file ...\n")
+		fmt.Fprint(out, "       filist keygen KEYFILE\n")
+		fmt.Fprint(out, "       filist verify-sig PUBKEYFILE MANIFEST\n\nFlags\n")
 		flagSet.PrintDefaults()
 	}
 	flagSet.SetOutput(out)
@@ -81,233 +172,516 @@ func run(arguments []string, out io.Writer) int {
 		return OK
 	}
 
+	if noConfig {
+		if profile != "" {
+			fmt.Fprint(out, "Error: --profile and --no-config cannot be specified at the same time")
+			return NG
+		}
+	} else {
+		// コマンドラインで指定されていないオプションは、設定ファイルの値を使う
+		if err := loadConfig(flagSet, profile); err != nil {
+			fmt.Fprintf(out, "Error: %v", err)
+			return NG
+		}
+	}
+
+	registry := filist.NewRegistry()
+
+	// 外部コマンドの列も --columns で表示位置を指定できるように、名前で登録しておく
+	execRunner := filist.NewExecRunner(execJobs, execTimeout)
+	var execNames []string
+	for _, spec := range execColumns {
+		name, command, err := parseExecColumn(spec)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v", err)
+			return NG
+		}
+		if err := execRunner.Register(registry, name, command); err != nil {
+			fmt.Fprintf(out, "Error: %v", err)
+			return NG
+		}
+		execNames = append(execNames, name)
+	}
+
+	if listColumns {
+		printColumns(out, registry)
+		return OK
+	}
+
 	dirs := flagSet.Args()
 
-	if len(dirs) == 0 {
+	if len(dirs) == 0 && fromFile == "" {
 		flagSet.Usage()
 		return NG
 	}
 
-	var columns []func(string, string, os.FileInfo) (string, error)
+	if level != 0 && minLevel > level {
+		fmt.Fprint(out, "Error: --min-level must be less than or equal to --level")
+		return NG
+	}
+
+	if noHidden && hiddenOnly {
+		fmt.Fprint(out, "Error: --no-hidden and --hidden-only cannot be specified at the same time")
+		return NG
+	}
 
-	if !printRelPath && !printAbsPath {
-		// relとabsどちらも指定されていなかった場合、先頭にrelを表示
-		columns = append(columns, getRelPath)
+	if oneFileSystem && !filist.DeviceIDSupported {
+		fmt.Fprint(out, "Error: --one-file-system is not supported on this platform")
+		return NG
 	}
 
-	// オプションは指定順に表示したいので
-	flagSet.Visit(func(f *flag.Flag) {
-		switch f.Name {
-		case "rel":
-			columns = append(columns, getRelPath)
-		case "abs":
-			columns = append(columns, getAbsPath)
-		case "size":
-			columns = append(columns, getSize)
-		case "mtime":
-			columns = append(columns, getMtime)
-		case "md5":
-			columns = append(columns, calcMd5)
-		case "sha1":
-			columns = append(columns, calcSha1)
-		case "sha256":
-			columns = append(columns, calcSha256)
+	if nestedArchives && !archives {
+		fmt.Fprint(out, "Error: --archives is required for --nested-archives")
+		return NG
+	}
+
+	var excludedMounts map[string]bool
+	if len(excludeFsTypes) != 0 {
+		mounts, err := filist.FindExcludedMounts(excludeFsTypes)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v", err)
+			return NG
 		}
-	})
+		excludedMounts = mounts
+	}
 
-	option := Option{
-		columns:            columns,
-		includeDirectories: includeDirectories,
-		excludeFiles:       excludeFiles,
-		level:              level,
+	if human && si {
+		fmt.Fprint(out, "Error: --human and --si cannot be specified at the same time")
+		return NG
 	}
 
-	err := print(out, dirs, option)
+	if precision < 0 {
+		fmt.Fprint(out, "Error: --precision must be 0 or more")
+		return NG
+	}
 
-	if err != nil {
-		fmt.Fprintf(out, "Error: %v", err)
+	if execJobs < 1 {
+		fmt.Fprint(out, "Error: --exec-jobs must be 1 or more")
 		return NG
 	}
 
-	return OK
-}
+	if resume && checkpointFile == "" {
+		fmt.Fprint(out, "Error: --checkpoint is required for --resume")
+		return NG
+	}
+
+	if resume && signKeyFile != "" {
+		// 署名は出力全体に対するものなので、途中からの出力には付けられない
+		fmt.Fprint(out, "Error: --sign and --resume cannot be specified at the same time")
+		return NG
+	}
+
+	if verifyFile != "" && (signKeyFile != "" || checkpointFile != "") {
+		fmt.Fprint(out, "Error: --verify cannot be specified with --sign or --checkpoint")
+		return NG
+	}
+
+	readRate, rateErr := parseRate(maxReadRate)
+	if rateErr != nil {
+		fmt.Fprintf(out, "Error: %v", rateErr)
+		return NG
+	}
+
+	if maxFilesPerSec < 0 {
+		fmt.Fprint(out, "Error: --max-files-per-sec must be 0 or more")
+		return NG
+	}
 
-func print(out io.Writer, dirs []string, option Option) error {
+	if dropCache && !filist.DropCacheSupported {
+		fmt.Fprint(out, "Error: --drop-cache is not supported on this platform")
+		return NG
+	}
+
+	if len(hmacNames) != 0 && keyFile == "" {
+		fmt.Fprint(out, "Error: --key-file is required for --hmac")
+		return NG
+	}
 
-	for _, dir := range dirs {
-		err := printDir(out, dir, option)
+	var hmacKey []byte
+	if keyFile != "" {
+		key, err := readKeyFile(keyFile)
 		if err != nil {
-			return err
+			fmt.Fprintf(out, "Error: %v", err)
+			return NG
 		}
+		hmacKey = key
 	}
 
-	return nil
-}
+	var signKey ed25519.PrivateKey
+	if signKeyFile != "" {
+		key, err := loadPrivateKey(signKeyFile)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v", err)
+			return NG
+		}
+		signKey = key
+	}
 
-func printDir(out io.Writer, dir string, option Option) error {
+	sizeFormat := filist.SizeFormatBytes
+	if human {
+		sizeFormat = filist.SizeFormatIEC
+	} else if si {
+		sizeFormat = filist.SizeFormatSI
+	}
 
-	absDir, err := filepath.Abs(dir)
-	if err != nil {
-		return err
+	var progress *filist.Progress
+	if showProgress && isTerminal(progressOut) {
+		// リダイレクトされている場合は、ログなどに制御文字が残らないように表示しない
+		progress = filist.NewProgress()
 	}
 
-	err = filepath.WalkDir(absDir, func(path string, d fs.DirEntry, err error) error {
+	// ファイルの内容から計算する列(ハッシュ、行数など)は、ファイルを1回読み込むだけでまとめて計算
+	scanner := filist.NewContentScanner()
+	scanner.Progress = progress
+
+	columnConfig := &filist.ColumnConfig{
+		Scanner:    scanner,
+		SizeFormat: sizeFormat,
+		Precision:  precision,
+		HMACKey:    hmacKey,
+		Progress:   progress,
+	}
+	var columns []filist.Column
+	addColumn := func(column filist.Column, err error) error {
 		if err != nil {
 			return err
 		}
+		columns = append(columns, column)
+		return nil
+	}
 
-		if absDir == path {
-			return nil
-		}
+	var columnErr error
 
-		if d.IsDir() {
-			if option.includeDirectories {
+	var specNames []string
+	if flagSet.Changed("columns") {
+		specNames = filist.ColumnNames(columnsSpec)
+	}
 
-				info, err := d.Info()
-				if err != nil {
-					return err
-				}
+	if !printRelPath && !printAbsPath && !flagSet.Changed("columns") {
+		// rel、abs、columnsのどれも指定されていなかった場合、先頭にrelを表示
+		columnErr = addColumn(registry.New("rel", "", columnConfig))
+	}
 
-				if err := printFileInfo(out, absDir, path, info, option); err != nil {
-					return err
+	// オプションは指定順に表示したいので
+	flagSet.Visit(func(f *flag.Flag) {
+		if columnErr != nil {
+			return
+		}
+
+		switch f.Name {
+		case "hash":
+			for _, name := range hashNames {
+				if columnErr = addColumn(scanner.HashColumn(name)); columnErr != nil {
+					return
 				}
 			}
-
-			depth, err := getDepth(absDir, path)
+		case "hmac":
+			for _, name := range hmacNames {
+				if columnErr = addColumn(scanner.HMACColumn(name, hmacKey)); columnErr != nil {
+					return
+				}
+			}
+		case "exec-column":
+			for _, name := range execNames {
+				if slices.Contains(specNames, name) {
+					// --columns で表示位置が指定されている
+					continue
+				}
+				if columnErr = addColumn(registry.New(name, "", columnConfig)); columnErr != nil {
+					return
+				}
+			}
+		case "columns":
+			specColumns, err := registry.Parse(columnsSpec, columnConfig)
 			if err != nil {
-				return err
+				columnErr = err
+				return
 			}
-
-			if option.level != 0 && depth >= int(option.level) {
-				// 指定レベル以上になったら、そのディレクトリ配下は見ない
-				return filepath.SkipDir
+			columns = append(columns, specColumns...)
+		default:
+			// 列の名前と同じオプションは、その列を表示 (--columns の指定の別名)
+			if _, ok := registry.Lookup(f.Name); ok {
+				columnErr = addColumn(registry.New(f.Name, "", columnConfig))
 			}
+		}
+	})
 
-		} else {
-			if !option.excludeFiles {
+	if columnErr != nil {
+		fmt.Fprintf(out, "Error: %v", columnErr)
+		return NG
+	}
 
-				info, err := d.Info()
-				if err != nil {
-					return err
-				}
+	walker := &filist.Walker{
+		IncludeDirectories: includeDirectories,
+		ExcludeFiles:       excludeFiles,
+		IncludeRoot:        includeRoot,
+		Level:              level,
+		MinLevel:           minLevel,
+		NoHidden:           noHidden,
+		HiddenOnly:         hiddenOnly,
+		OneFileSystem:      oneFileSystem,
+		ExcludedMounts:     excludedMounts,
+		Archives:           archives,
+		NestedArchives:     nestedArchives,
+		PrefixRoot:         prefixRoot,
+		BaseDir:            baseDir,
+		Progress:           progress,
+	}
 
-				return printFileInfo(out, absDir, path, info, option)
-			}
+	if readRate > 0 || maxFilesPerSec > 0 || dropCache {
+		walker.Throttle = filist.NewThrottle(readRate, maxFilesPerSec, dropCache)
+	}
+
+	var paths []string
+	if fromFile != "" {
+		var err error
+		paths, err = readPathsFile(fromFile)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v", err)
+			return NG
 		}
+	}
 
-		return nil
-	})
+	// Ctrl-C などで中断された場合や、タイムアウトした場合は、途中のエントリを出力せずに走査を止める
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	return err
-}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
-func getDepth(basePath string, path string) (int, error) {
+	// 中断した後は、読み込みが止まらない場合でも2回目の Ctrl-C で終了できるように、シグナルの扱いを元に戻す
+	context.AfterFunc(ctx, stop)
 
-	relPath, err := filepath.Rel(basePath, path)
-	if err != nil {
-		return 0, err
+	if progress != nil {
+		if walker.Throttle == nil {
+			// 全体の数は、表示しながら別に走査して求める (求まるまではETAは表示しない)
+			// 速度を制限している場合は、ディスクへの負荷を増やさないように全体の数は求めない
+			go prescan(ctx, *walker, dirs, paths, progress)
+		}
+
+		reporter := startProgress(progressOut, progress, progressInterval)
+		defer reporter.stop()
 	}
 
-	return len(strings.Split(relPath, string(filepath.Separator))), nil
-}
+	formatter := &filist.TSVFormatter{
+		NullTerminated: nullTerminated,
+		Escape:         escape,
+	}
+
+	var signer *signer
+	if signKey != nil {
+		// 出力内容全体に対して署名
+		signer = newSigner(out, signKey, nullTerminated)
+		out = signer
+	}
 
-func printFileInfo(out io.Writer, baseDir string, filePath string, info os.FileInfo, option Option) error {
+	// 一覧の出力先 (検証する場合は、出力せずにマニフェストと突き合わせる)
+	rows := out
+	var verifier *verifier
+	if verifyFile != "" {
+		manifest, err := os.ReadFile(verifyFile)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v", err)
+			return NG
+		}
+		verifier = newVerifier(manifest, nullTerminated)
+		rows = verifier
+	}
+
+	var checkpoint *checkpoint
+	if checkpointFile != "" {
+		columnNames := make([]string, len(columns))
+		for i, column := range columns {
+			columnNames[i] = column.Name()
+		}
 
-	for i, column := range option.columns {
-		if i > 0 {
-			fmt.Fprintf(out, "\t")
+		options := outputOptions(dirs, paths, columnNames, nullTerminated, escape)
+		c, err := openCheckpoint(checkpointFile, options, resume)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v", err)
+			return NG
 		}
-		value, err := column(baseDir, filePath, info)
+		defer c.close()
+		checkpoint = c
+	}
+
+	// 中断された場合に、どこまで出力したかを示すため
+	var lastPath string
+
+	printEntry := func(entry *filist.Entry) error {
+		values, err := filist.Values(entry, columns)
 		if err != nil {
 			return err
 		}
-		fmt.Fprintf(out, "%s", value)
+		// 1エントリ分をまとめて出力するので、途中で中断されても不完全な行は出力されない
+		if err := formatter.Format(rows, columns, values); err != nil {
+			return err
+		}
+		lastPath = entry.Path
+
+		if checkpoint != nil {
+			return checkpoint.record(entry.Path)
+		}
+		return nil
 	}
 
-	fmt.Fprintln(out)
+	// 外部コマンドは後続のエントリの分も先に実行しておく (出力は走査の順)
+	walkEntry, flushEntries := execRunner.Lookahead(printEntry)
 
-	return nil
-}
+	if checkpoint != nil {
+		// 前回までに出力済みのエントリは、列の値も計算せずに読み飛ばす
+		next := walkEntry
+		walkEntry = func(entry *filist.Entry) error {
+			skip, err := checkpoint.skip(entry.Path)
+			if err != nil {
+				return err
+			}
+			if skip {
+				return filist.SkipEntry
+			}
+			return next(entry)
+		}
+	}
 
-func getRelPath(baseDir string, filePath string, info os.FileInfo) (string, error) {
+	err := walker.Walk(ctx, dirs, walkEntry)
 
-	relPath, err := filepath.Rel(baseDir, filePath)
-	if err != nil {
-		return "", err
+	if err == nil && fromFile != "" {
+		err = walker.WalkPaths(ctx, paths, walkEntry)
 	}
 
-	if info.IsDir() {
-		relPath = relPath + string(filepath.Separator)
+	if err == nil {
+		err = flushEntries()
 	}
 
-	return relPath, nil
-}
+	if err == nil && checkpoint != nil {
+		err = checkpoint.finish()
+	}
 
-func getAbsPath(baseDir string, filePath string, info os.FileInfo) (string, error) {
+	if err != nil {
+		if ctx.Err() != nil {
+			fmt.Fprintf(errorOut, "Error: %s\n", interruptedMessage(ctx, timeout, lastPath))
+			return NG
+		}
+		fmt.Fprintf(out, "Error: %v", err)
+		return NG
+	}
 
-	if info.IsDir() {
-		filePath = filePath + string(filepath.Separator)
+	if signer != nil {
+		if err := signer.writeSignature(); err != nil {
+			fmt.Fprintf(out, "Error: %v", err)
+			return NG
+		}
 	}
 
-	return filePath, nil
+	if verifier != nil {
+		if err := verifier.report(out); err != nil {
+			fmt.Fprintf(out, "Error: %v", err)
+			return NG
+		}
+		fmt.Fprintln(out, "OK")
+	}
+
+	return OK
 }
 
-func getSize(baseDir string, filePath string, info os.FileInfo) (string, error) {
+// interruptedMessage 中断された理由と、最後に出力したエントリのパス
+func interruptedMessage(ctx context.Context, timeout time.Duration, lastPath string) string {
+
+	message := "interrupted"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		message = fmt.Sprintf("timed out after %s", timeout)
+	}
 
-	if info.IsDir() {
-		return "", nil
+	if lastPath == "" {
+		return message + " (no entries completed)"
 	}
 
-	return fmt.Sprint(info.Size()), nil
+	return fmt.Sprintf("%s (last completed: %s)", message, lastPath)
 }
 
-func getMtime(baseDir string, filePath string, info os.FileInfo) (string, error) {
+// parseRate --max-read-rate の1秒あたりのバイト数 (空の場合は無制限の0)
+// 単位は K, M, G, T (1024倍ごと) で、後ろの B, iB は省略できる
+func parseRate(rate string) (int64, error) {
 
-	if info.IsDir() {
-		return "", nil
+	if rate == "" {
+		return 0, nil
 	}
 
-	return info.ModTime().Format("2006-01-02T15:04:05.000000-07:00"), nil
-}
-
-func calcMd5(baseDir string, filePath string, info os.FileInfo) (string, error) {
+	number := strings.ToUpper(strings.TrimSpace(rate))
+	unitRequired := strings.HasSuffix(number, "IB")
+	number = strings.TrimSuffix(strings.TrimSuffix(number, "B"), "I")
+
+	multiplier := int64(1)
+	if i := strings.LastIndexAny(number, "KMGT"); i >= 0 && i == len(number)-1 {
+		multiplier = int64(1) << (10 * (strings.IndexByte("KMGT", number[i]) + 1))
+		number = number[:i]
+	} else if unitRequired {
+		// 単位無しの iB は不正
+		number = ""
+	}
 
-	if info.IsDir() {
-		return "", nil
+	// 1バイト未満は0 (制限無し) になってしまうため不正とする
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || math.IsNaN(value) || value*float64(multiplier) < 1 || value*float64(multiplier) >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid --max-read-rate (e.g. 50M): %s", rate)
 	}
 
-	return calcHash(filePath, md5.New())
+	return int64(value * float64(multiplier)), nil
 }
 
-func calcSha1(baseDir string, filePath string, info os.FileInfo) (string, error) {
+func readKeyFile(keyFile string) ([]byte, error) {
 
-	if info.IsDir() {
-		return "", nil
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
 	}
 
-	return calcHash(filePath, sha1.New())
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key file is empty: %s", keyFile)
+	}
+
+	return key, nil
 }
 
-func calcSha256(baseDir string, filePath string, info os.FileInfo) (string, error) {
+// printColumns --columns で指定できる列の一覧を表示する
+func printColumns(out io.Writer, registry *filist.Registry) {
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
 
-	if info.IsDir() {
-		return "", nil
+	fmt.Fprintln(w, "NAME\tOPTIONS\tDESCRIPTION")
+	for _, definition := range registry.Definitions() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", definition.Name, definition.Options, definition.Description)
 	}
 
-	return calcHash(filePath, sha256.New())
+	w.Flush()
 }
 
-func calcHash(filePath string, hash hash.Hash) (string, error) {
+// parseExecColumn NAME=COMMAND 形式の外部コマンドの列の指定を分割する
+func parseExecColumn(spec string) (string, string, error) {
 
-	f, err := os.Open(filePath)
-	if err != nil {
-		return "", err
+	name, command, ok := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.TrimSpace(command) == "" {
+		return "", "", fmt.Errorf("invalid --exec-column (NAME='command {}'): %s", spec)
 	}
-	defer f.Close()
 
-	if _, err := io.Copy(hash, f); err != nil {
-		return "", err
+	return name, command, nil
+}
+
+func readPathsFile(fromFile string) ([]string, error) {
+
+	var r io.Reader = os.Stdin
+	if fromFile != "-" {
+		f, err := os.Open(fromFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
 	}
 
-	return hex.EncodeToString(hash.Sum(nil)), nil
+	return filist.ReadPaths(r)
 }
@@ -1,14 +1,27 @@
 package main
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/onozaty/filist/filist"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -18,7 +31,15 @@ func TestMain(m *testing.M) {
 	loc, _ := time.LoadLocation("UTC")
 	time.Local = loc
 
+	// 実行環境のユーザの設定ファイルを読まないように
+	configHome, err := os.MkdirTemp("", "filist-config")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CONFIG_HOME", configHome)
+
 	code := m.Run()
+	os.RemoveAll(configHome)
 	os.Exit(code)
 }
 
@@ -513,7 +534,7 @@ func TestRun_Level3(t *testing.T) {
 	assert.Equal(t, expected, out.String())
 }
 
-func TestRun_MultiDir(t *testing.T) {
+func TestRun_MinLevel(t *testing.T) {
 
 	// ARRANGE
 	temp := t.TempDir()
@@ -525,9 +546,10 @@ func TestRun_MultiDir(t *testing.T) {
 	// ACT
 	exitCode := run(
 		[]string{
-			"-a",
-			filepath.Join(temp, "a", "xxx"),
-			filepath.Join(temp, "x", "y", "z"),
+			temp,
+			"--min-level", "2",
+			"--include-dir",
+			"--depth",
 		},
 		out,
 	)
@@ -536,13 +558,20 @@ func TestRun_MultiDir(t *testing.T) {
 	require.Equal(t, OK, exitCode)
 
 	expected := allLines(
-		line(filepath.Join(temp, "a", "xxx", "x.txt")),
-		line(filepath.Join(temp, "x", "y", "z", "テスト.txt")),
+		line(filepath.Join("a", "a.txt"), "2"),
+		line(filepath.Join("a", "b.txt"), "2"),
+		line(filepath.Join("a", "xxx")+string(filepath.Separator), "2"),
+		line(filepath.Join("a", "xxx", "x.txt"), "3"),
+		line(filepath.Join("a", "xxx", "yyy")+string(filepath.Separator), "3"),
+		line(filepath.Join("a", "xxx", "zzz")+string(filepath.Separator), "3"),
+		line(filepath.Join("x", "y")+string(filepath.Separator), "2"),
+		line(filepath.Join("x", "y", "z")+string(filepath.Separator), "3"),
+		line(filepath.Join("x", "y", "z", "テスト.txt"), "4"),
 	)
 	assert.Equal(t, expected, out.String())
 }
 
-func TestRun_DirNotFound(t *testing.T) {
+func TestRun_MinLevel_ExactLevel(t *testing.T) {
 
 	// ARRANGE
 	temp := t.TempDir()
@@ -551,23 +580,53 @@ func TestRun_DirNotFound(t *testing.T) {
 
 	out := new(bytes.Buffer)
 
-	targetDir := filepath.Join(temp, "___") // 存在しない
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--min-level", "2",
+			"-l", "2",
+			"--include-dir",
+			"--exclude-file",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line(filepath.Join("a", "xxx")+string(filepath.Separator)),
+		line(filepath.Join("x", "y")+string(filepath.Separator)),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_MinLevel_GreaterThanLevel(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
 
 	// ACT
 	exitCode := run(
 		[]string{
-			targetDir,
+			temp,
+			"--min-level", "3",
+			"-l", "2",
 		},
 		out,
 	)
 
 	// ASSERT
 	require.Equal(t, NG, exitCode)
-	assert.Contains(t, out.String(), "Error: ") // 実況環境で異なるので
-	assert.Contains(t, out.String(), targetDir)
+	assert.Equal(t, "Error: --min-level must be less than or equal to --level", out.String())
 }
 
-func TestRun_Help(t *testing.T) {
+func TestRun_Depth_File(t *testing.T) {
 
 	// ARRANGE
 	temp := t.TempDir()
@@ -579,7 +638,9 @@ func TestRun_Help(t *testing.T) {
 	// ACT
 	exitCode := run(
 		[]string{
-			"-h",
+			"--depth",
+			filepath.Join(temp, "a", "xxx", "x.txt"),
+			"--base", temp,
 		},
 		out,
 	)
@@ -587,27 +648,13 @@ func TestRun_Help(t *testing.T) {
 	// ASSERT
 	require.Equal(t, OK, exitCode)
 
-	expected := `filist vdev (dev)
-
-Usage: filist [flags] directory ...
-
-Flags
-  -r, --rel            Print relative path (If neither 'rel' nor 'abs' is specified, 'rel' will be printed first column.)
-  -a, --abs            Print absolute path
-  -s, --size           Print file size
-  -m, --mtime          Print modification time
-  -M, --md5            Print MD5 hash
-  -S, --sha1           Print SHA-1 hash
-      --sha256         Print SHA-256 hash
-      --include-dir    Include directories
-      --exclude-file   Exclude files
-  -l, --level int      Number of directory level (Default is unlimited)
-  -h, --help           Help
-`
+	expected := allLines(
+		line(filepath.Join("a", "xxx", "x.txt"), "3"),
+	)
 	assert.Equal(t, expected, out.String())
 }
 
-func TestRun_NoArgs(t *testing.T) {
+func TestRun_Depth_File_OutsideBase(t *testing.T) {
 
 	// ARRANGE
 	temp := t.TempDir()
@@ -618,191 +665,2701 @@ func TestRun_NoArgs(t *testing.T) {
 
 	// ACT
 	exitCode := run(
-		[]string{},
+		[]string{
+			"--depth",
+			filepath.Join(temp, "1.txt"),
+			"--base", filepath.Join(temp, "a", "xxx"),
+		},
 		out,
 	)
 
 	// ASSERT
-	require.Equal(t, NG, exitCode)
-
-	expected := `filist vdev (dev)
-
-Usage: filist [flags] directory ...
+	require.Equal(t, OK, exitCode)
 
-Flags
-  -r, --rel            Print relative path (If neither 'rel' nor 'abs' is specified, 'rel' will be printed first column.)
-  -a, --abs            Print absolute path
-  -s, --size           Print file size
-  -m, --mtime          Print modification time
-  -M, --md5            Print MD5 hash
-  -S, --sha1           Print SHA-1 hash
-      --sha256         Print SHA-256 hash
-      --include-dir    Include directories
-      --exclude-file   Exclude files
-  -l, --level int      Number of directory level (Default is unlimited)
-  -h, --help           Help
-`
+	// ベースディレクトリの外は階層が無い
+	expected := allLines(
+		line(filepath.Join("..", "..", "1.txt"), ""),
+	)
 	assert.Equal(t, expected, out.String())
 }
 
-func TestRelPath(t *testing.T) {
+func TestRun_OneFileSystem(t *testing.T) {
+
+	if !filist.DeviceIDSupported {
+		t.Skip("device ID is not supported on this platform")
+	}
 
 	// ARRANGE
 	temp := t.TempDir()
-	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
 
 	// ACT
-	result, err := getRelPath(temp, filePath, info)
+	exitCode := run(
+		[]string{
+			temp,
+			"--one-file-system",
+		},
+		out,
+	)
 
 	// ASSERT
-	require.NoError(t, err)
-	assert.Equal(t, "hoge.txt", result)
+	require.Equal(t, OK, exitCode)
+
+	// 同じファイルシステム内は全て表示
+	expected := allLines(
+		line("1.txt"),
+		line(filepath.Join("a", "a.txt")),
+		line(filepath.Join("a", "b.txt")),
+		line(filepath.Join("a", "xxx", "x.txt")),
+		line(filepath.Join("x", "y", "z", "テスト.txt")),
+	)
+	assert.Equal(t, expected, out.String())
 }
 
-func TestAbsPath(t *testing.T) {
+func TestRun_Archives(t *testing.T) {
 
 	// ARRANGE
 	temp := t.TempDir()
-	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	setupFile(t, temp, "1.txt", "x", "2020-01-01T00:00:00")
+	writeArchiveFile(t, filepath.Join(temp, "a.zip"), createZip(t, map[string]string{
+		"a.txt":   "A",
+		"d/b.txt": "BB",
+	}))
+	writeArchiveFile(t, filepath.Join(temp, "b", "c.tar.gz"), gzipData(t, createTar(t, map[string]string{
+		"c.txt": "CCC",
+	})))
+
+	out := new(bytes.Buffer)
 
 	// ACT
-	result, err := getAbsPath(temp, filePath, info)
+	exitCode := run(
+		[]string{
+			temp,
+			"--archives",
+			"--include-dir",
+			"--size",
+			"--mtime",
+			"--md5",
+		},
+		out,
+	)
 
 	// ASSERT
-	require.NoError(t, err)
-	assert.Equal(t, filePath, result)
+	require.Equal(t, OK, exitCode)
+
+	mtime := "2020-01-01T00:00:00.000000+00:00"
+	expected := allLines(
+		line("1.txt", "1", mtime, "9dd4e461268c8034f5c8564e155c67a6"),
+		line("a.zip"+string(filepath.Separator), "", "", ""),
+		line(filepath.Join("a.zip", "a.txt"), "1", mtime, "7fc56270e7a70fa81a5935b72eacbe29"),
+		line(filepath.Join("a.zip", "d")+string(filepath.Separator), "", "", ""),
+		line(filepath.Join("a.zip", "d", "b.txt"), "2", mtime, "9d3d9048db16a7eee539e93e3618cbe7"),
+		line("b"+string(filepath.Separator), "", "", ""),
+		line(filepath.Join("b", "c.tar.gz")+string(filepath.Separator), "", "", ""),
+		line(filepath.Join("b", "c.tar.gz", "c.txt"), "3", mtime, "defb99e69a9f1f6e06f15006b1f166ae"),
+	)
+	assert.Equal(t, expected, out.String())
 }
 
-func TestGetSize(t *testing.T) {
+func TestRun_Archives_Level(t *testing.T) {
 
 	// ARRANGE
 	temp := t.TempDir()
-	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	writeArchiveFile(t, filepath.Join(temp, "a.zip"), createZip(t, map[string]string{
+		"a.txt":   "A",
+		"d/b.txt": "BB",
+	}))
+
+	out := new(bytes.Buffer)
 
 	// ACT
-	result, err := getSize(temp, filePath, info)
+	exitCode := run(
+		[]string{
+			temp,
+			"--archives",
+			"--include-dir",
+			"--level", "2",
+			"--depth",
+		},
+		out,
+	)
 
 	// ASSERT
-	require.NoError(t, err)
-	assert.Equal(t, "7", result)
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.zip"+string(filepath.Separator), "1"),
+		line(filepath.Join("a.zip", "a.txt"), "2"),
+		line(filepath.Join("a.zip", "d")+string(filepath.Separator), "2"),
+	)
+	assert.Equal(t, expected, out.String())
 }
 
-func TestGetMtime(t *testing.T) {
+func TestRun_Archives_Nested(t *testing.T) {
 
 	// ARRANGE
 	temp := t.TempDir()
-	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "2011-01-02T12:13:14")
 
-	// ACT
-	result, err := getMtime(temp, filePath, info)
+	inner := createZip(t, map[string]string{
+		"inner.txt": "I",
+	})
+	writeArchiveFile(t, filepath.Join(temp, "outer.tar"), createTar(t, map[string]string{
+		"inner.zip": string(inner),
+	}))
+
+	tests := []struct {
+		name      string
+		arguments []string
+		expected  string
+	}{
+		{
+			name:      "archives",
+			arguments: []string{"--archives"},
+			expected: allLines(
+				line(filepath.Join("outer.tar", "inner.zip")),
+			),
+		},
+		{
+			name:      "nested-archives",
+			arguments: []string{"--archives", "--nested-archives"},
+			expected: allLines(
+				line(filepath.Join("outer.tar", "inner.zip", "inner.txt")),
+			),
+		},
+		{
+			name:      "none",
+			arguments: []string{},
+			expected: allLines(
+				line("outer.tar"),
+			),
+		},
+	}
 
-	// ASSERT
-	require.NoError(t, err)
-	assert.Equal(t, "2011-01-02T12:13:14.000000+00:00", result)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			out := new(bytes.Buffer)
+
+			// ACT
+			exitCode := run(append([]string{temp}, tt.arguments...), out)
+
+			// ASSERT
+			require.Equal(t, OK, exitCode)
+			assert.Equal(t, tt.expected, out.String())
+		})
+	}
 }
 
-func TestCalcMd5(t *testing.T) {
+func TestRun_Archives_Invalid(t *testing.T) {
 
 	// ARRANGE
 	temp := t.TempDir()
-	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	setupFile(t, temp, "a.zip", "not zip", "")
+
+	out := new(bytes.Buffer)
 
 	// ACT
-	result, err := calcMd5(temp, filePath, info)
+	exitCode := run(
+		[]string{
+			temp,
+			"--archives",
+		},
+		out,
+	)
 
 	// ASSERT
-	require.NoError(t, err)
-	assert.Equal(t, "bb747b3df3130fe1ca4afa93fb7d97c9", result)
+	require.Equal(t, NG, exitCode)
+	assert.Contains(t, out.String(), "Error: "+filepath.Join(temp, "a.zip")+": ")
 }
 
-func TestCalcMd5_empty(t *testing.T) {
+func TestRun_NestedArchives_WithoutArchives(t *testing.T) {
 
 	// ARRANGE
 	temp := t.TempDir()
-	filePath, info := setupFile(t, temp, "hoge.txt", "", "")
+
+	out := new(bytes.Buffer)
 
 	// ACT
-	result, err := calcMd5(temp, filePath, info)
+	exitCode := run(
+		[]string{
+			temp,
+			"--nested-archives",
+		},
+		out,
+	)
 
 	// ASSERT
-	require.NoError(t, err)
-	assert.Equal(t, "d41d8cd98f00b204e9800998ecf8427e", result)
+	require.Equal(t, NG, exitCode)
+	assert.Equal(t, "Error: --archives is required for --nested-archives", out.String())
 }
 
-func TestCalcSha1(t *testing.T) {
+func TestRun_NoHidden(t *testing.T) {
 
 	// ARRANGE
 	temp := t.TempDir()
-	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	setupFiles(t, temp)
+	setupFile(t, temp, ".hidden.txt", "", "")
+	setupFile(t, filepath.Join(temp, ".git"), "config", "", "")
+	setupFile(t, filepath.Join(temp, "a", ".cache"), "c.txt", "", "")
+
+	out := new(bytes.Buffer)
 
 	// ACT
-	result, err := calcSha1(temp, filePath, info)
+	exitCode := run(
+		[]string{
+			temp,
+			"--no-hidden",
+			"--include-dir",
+			"-l", "2",
+		},
+		out,
+	)
 
 	// ASSERT
-	require.NoError(t, err)
-	assert.Equal(t, "93be4612c41d23af1891dac5fd0d535736ffc4e3", result)
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("1.txt"),
+		line("a"+string(filepath.Separator)),
+		line(filepath.Join("a", "a.txt")),
+		line(filepath.Join("a", "b.txt")),
+		line(filepath.Join("a", "xxx")+string(filepath.Separator)),
+		line("x"+string(filepath.Separator)),
+		line(filepath.Join("x", "y")+string(filepath.Separator)),
+	)
+	assert.Equal(t, expected, out.String())
 }
 
-func TestCalcSha1_empty(t *testing.T) {
+func TestRun_HiddenOnly(t *testing.T) {
 
 	// ARRANGE
 	temp := t.TempDir()
-	filePath, info := setupFile(t, temp, "hoge.txt", "", "")
+
+	setupFiles(t, temp)
+	setupFile(t, temp, ".hidden.txt", "", "")
+	setupFile(t, filepath.Join(temp, ".git"), "config", "", "")
+	setupFile(t, filepath.Join(temp, "a", "xxx", ".cache"), "c.txt", "", "")
+
+	out := new(bytes.Buffer)
 
 	// ACT
-	result, err := calcSha1(temp, filePath, info)
+	exitCode := run(
+		[]string{
+			temp,
+			"--hidden-only",
+			"--include-dir",
+		},
+		out,
+	)
 
 	// ASSERT
-	require.NoError(t, err)
-	assert.Equal(t, "da39a3ee5e6b4b0d3255bfef95601890afd80709", result)
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line(".git"+string(filepath.Separator)),
+		line(filepath.Join(".git", "config")),
+		line(".hidden.txt"),
+		line(filepath.Join("a", "xxx", ".cache")+string(filepath.Separator)),
+		line(filepath.Join("a", "xxx", ".cache", "c.txt")),
+	)
+	assert.Equal(t, expected, out.String())
 }
 
-func TestCalcSha256(t *testing.T) {
+func TestRun_NoHiddenAndHiddenOnly(t *testing.T) {
 
 	// ARRANGE
 	temp := t.TempDir()
-	filePath, info := setupFile(t, temp, "hoge.txt", "ABCDEFG", "")
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
 
 	// ACT
-	result, err := calcSha256(temp, filePath, info)
+	exitCode := run(
+		[]string{
+			temp,
+			"--no-hidden",
+			"--hidden-only",
+		},
+		out,
+	)
 
 	// ASSERT
-	require.NoError(t, err)
-	assert.Equal(t, "e9a92a2ed0d53732ac13b031a27b071814231c8633c9f41844ccba884d482b16", result)
+	require.Equal(t, NG, exitCode)
+	assert.Equal(t, "Error: --no-hidden and --hidden-only cannot be specified at the same time", out.String())
 }
 
-func TestCalcSha256_empty(t *testing.T) {
+func TestRun_MultiDir(t *testing.T) {
 
 	// ARRANGE
 	temp := t.TempDir()
-	filePath, info := setupFile(t, temp, "hoge.txt", "", "")
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
 
 	// ACT
-	result, err := calcSha256(temp, filePath, info)
+	exitCode := run(
+		[]string{
+			"-a",
+			filepath.Join(temp, "a", "xxx"),
+			filepath.Join(temp, "x", "y", "z"),
+		},
+		out,
+	)
 
 	// ASSERT
-	require.NoError(t, err)
-	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", result)
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line(filepath.Join(temp, "a", "xxx", "x.txt")),
+		line(filepath.Join(temp, "x", "y", "z", "テスト.txt")),
+	)
+	assert.Equal(t, expected, out.String())
 }
 
-func setupDir(t *testing.T, dir string) {
+func TestRun_File(t *testing.T) {
 
-	err := os.MkdirAll(dir, 0777)
-	require.NoError(t, err)
-}
+	// ARRANGE
+	temp := t.TempDir()
 
-func setupFile(t *testing.T, dir string, name string, contents string, mtimeStr string) (string, fs.FileInfo) {
+	setupFiles(t, temp)
 
-	setupDir(t, dir)
+	out := new(bytes.Buffer)
 
-	filePath := filepath.Join(dir, name)
+	// ACT
+	exitCode := run(
+		[]string{
+			"-s",
+			filepath.Join(temp, "a", "b.txt"),
+			filepath.Join(temp, "x"),
+			filepath.Join(temp, "1.txt"),
+		},
+		out,
+	)
 
-	file, err := os.Create(filePath)
-	require.NoError(t, err)
-	defer file.Close()
+	// ASSERT
+	require.Equal(t, OK, exitCode)
 
-	_, err = file.Write([]byte(contents))
-	require.NoError(t, err)
+	expected := allLines(
+		line("b.txt", "10"),
+		line(filepath.Join("y", "z", "テスト.txt"), "100"),
+		line("1.txt", "0"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_File_Base(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"--base", temp,
+			filepath.Join(temp, "a", "b.txt"),
+			filepath.Join(temp, "a", "xxx", "x.txt"),
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line(filepath.Join("a", "b.txt")),
+		line(filepath.Join("a", "xxx", "x.txt")),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_File_ExcludeFile(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"--exclude-file",
+			filepath.Join(temp, "1.txt"),
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+	assert.Equal(t, "", out.String())
+}
+
+func TestRun_Root(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	t.Chdir(temp)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"--root",
+			"-r",
+			"--root-abs",
+			filepath.Join("a", "xxx"),
+			"x",
+			"1.txt",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line(filepath.Join("a", "xxx"), "x.txt", filepath.Join(temp, "a", "xxx")),
+		line("x", filepath.Join("y", "z", "テスト.txt"), filepath.Join(temp, "x")),
+		line("1.txt", "1.txt", filepath.Join(temp, "1.txt")),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_PrefixRoot(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"--prefix-root",
+			"--include-dir",
+			filepath.Join(temp, "a", "xxx"),
+			filepath.Join(temp, "x", "y"),
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line(filepath.Join("xxx", "x.txt")),
+		line(filepath.Join("xxx", "yyy")+string(filepath.Separator)),
+		line(filepath.Join("xxx", "zzz")+string(filepath.Separator)),
+		line(filepath.Join("y", "z")+string(filepath.Separator)),
+		line(filepath.Join("y", "z", "テスト.txt")),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_PrefixRoot_FromFile(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	listFile, _ := setupFile(t, t.TempDir(), "list.txt", "b.txt\nxxx/x.txt\n", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"--from-file", listFile,
+			"--base", filepath.Join(temp, "a"),
+			"--prefix-root",
+			"--root",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line(filepath.Join("a", "b.txt"), filepath.Join(temp, "a")),
+		line(filepath.Join("a", "xxx", "x.txt"), filepath.Join(temp, "a")),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_DirNotFound(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
+
+	targetDir := filepath.Join(temp, "___") // 存在しない
+
+	// ACT
+	exitCode := run(
+		[]string{
+			targetDir,
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+	assert.Contains(t, out.String(), "Error: ") // 実況環境で異なるので
+	assert.Contains(t, out.String(), targetDir)
+}
+
+func TestRun_Help(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"-h",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := `filist vdev (dev)
+
+Usage: filist [flags] directory|file ...
+       filist keygen KEYFILE
+       filist verify-sig PUBKEYFILE MANIFEST
+
+Flags
+  -r, --rel                       Print relative path (If neither 'rel' nor 'abs' nor 'columns' is specified, 'rel' will be printed first column.)
+  -a, --abs                       Print absolute path
+      --root                      Print root (directory or file as specified in the arguments)
+      --root-abs                  Print absolute path of root
+      --prefix-root               Prefix relative path with the name of root directory
+  -s, --size                      Print file size
+      --human                     Print file size in human-readable format with IEC units (KiB, MiB, ...)
+      --si                        Print file size in human-readable format with SI units (kB, MB, ...)
+      --precision int             Number of decimal places for human-readable size (default 1)
+  -m, --mtime                     Print modification time
+      --depth                     Print directory level
+      --lines                     Print number of lines (LF and CRLF are each counted as one line break, '-' for binary files)
+      --words                     Print number of words ('-' for binary files)
+      --chars                     Print number of characters (CRLF is counted as one character, '-' for binary files)
+      --encoding                  Print text encoding (ASCII, UTF-8, UTF-8-BOM, UTF-16LE, UTF-16BE, invalid-UTF-8)
+      --eol                       Print line ending (LF, CRLF, CR, mixed, none)
+      --mime                      Print content type detected from the head of the file
+      --text-binary               Print whether the file is text or binary
+  -M, --md5                       Print MD5 hash
+  -S, --sha1                      Print SHA-1 hash
+      --sha256                    Print SHA-256 hash
+      --hash strings              Print hashes of the specified algorithms, comma separated (md5, sha1, sha224, sha256, sha384, sha512, sha512-224, sha512-256, sha3-224, sha3-256, sha3-384, sha3-512, crc32, crc32c, crc64-iso, crc64-ecma, adler32, fnv32, fnv32a, fnv64, fnv64a, fnv128, fnv128a)
+      --hmac strings              Print HMACs of the specified algorithms, comma separated (md5, sha1, sha224, sha256, sha384, sha512, sha512-224, sha512-256, sha3-224, sha3-256, sha3-384, sha3-512)
+      --key-file string           Key file for HMAC
+      --tree-hash                 Print Merkle tree hash (SHA-256 of contents for files, hash of all children for directories)
+      --exec-column stringArray   Print output of an external command as a column (NAME='command {}', {} is replaced with the file path)
+      --exec-timeout duration     Timeout for each command of --exec-column (0 is unlimited) (default 30s)
+      --exec-jobs int             Maximum number of commands of --exec-column to run at the same time (default 4)
+      --columns string            Print the specified columns, comma separated, with an option after ':' (e.g. rel,size:human,mtime:unix,sha256:12)
+      --list-columns              List columns available for --columns and their options
+      --include-dir               Include directories
+      --exclude-file              Exclude files
+      --include-root              Include the specified directories themselves (e.g. to print --tree-hash of the whole tree)
+  -l, --level int                 Number of directory level (Default is unlimited)
+      --no-hidden                 Exclude hidden files and directories (names starting with '.')
+      --hidden-only               Print only hidden files and directories, and entries under hidden directories
+      --one-file-system           Do not descend into directories on other file systems
+      --exclude-fstype strings    Skip mount points of the specified file system types, comma separated (e.g. nfs,tmpfs,proc)
+      --archives                  List contents of archives (.zip, .tar, .tar.gz, .tgz) as directories
+      --nested-archives           Also list contents of archives inside archives (requires --archives)
+      --min-level int             Minimum directory level to print (Default is unlimited)
+      --from-file string          Read paths from the specified file instead of walking directories ('-' for stdin, newline or NUL delimited)
+      --base string               Base directory for relative paths of file arguments and --from-file (Default is the parent directory for file arguments, current directory for --from-file)
+  -0, --null                      Terminate each line with NUL instead of newline
+      --escape                    Escape control characters (\n, \t, \xHH, ...) and backslashes in the output
+      --sign string               Append Ed25519 signature of the output with the specified private key file
+      --verify string             Compare the listing with the specified manifest instead of printing it (specify the same flags and --key-file as when the manifest was created)
+      --progress                  Print progress on stderr (only if stderr is a terminal)
+      --timeout duration          Stop listing after the specified duration (e.g. 30m, 0 is unlimited)
+      --checkpoint string         Record the printed entries to the specified file, to resume the listing with --resume
+      --resume                    Skip the entries already printed according to --checkpoint, to append the rest to the previous output
+      --max-read-rate string      Limit the bytes read per second for content columns (e.g. 50M, units are K, M, G and T in 1024)
+      --max-files-per-sec float   Limit the files listed per second (0 is unlimited)
+      --drop-cache                Advise the OS to drop the page cache of files after reading them (Linux only)
+      --profile string            Use the named profile in config files
+      --no-config                 Do not read config files
+  -h, --help                      Help
+`
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_NoArgs(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+
+	expected := `filist vdev (dev)
+
+Usage: filist [flags] directory|file ...
+       filist keygen KEYFILE
+       filist verify-sig PUBKEYFILE MANIFEST
+
+Flags
+  -r, --rel                       Print relative path (If neither 'rel' nor 'abs' nor 'columns' is specified, 'rel' will be printed first column.)
+  -a, --abs                       Print absolute path
+      --root                      Print root (directory or file as specified in the arguments)
+      --root-abs                  Print absolute path of root
+      --prefix-root               Prefix relative path with the name of root directory
+  -s, --size                      Print file size
+      --human                     Print file size in human-readable format with IEC units (KiB, MiB, ...)
+      --si                        Print file size in human-readable format with SI units (kB, MB, ...)
+      --precision int             Number of decimal places for human-readable size (default 1)
+  -m, --mtime                     Print modification time
+      --depth                     Print directory level
+      --lines                     Print number of lines (LF and CRLF are each counted as one line break, '-' for binary files)
+      --words                     Print number of words ('-' for binary files)
+      --chars                     Print number of characters (CRLF is counted as one character, '-' for binary files)
+      --encoding                  Print text encoding (ASCII, UTF-8, UTF-8-BOM, UTF-16LE, UTF-16BE, invalid-UTF-8)
+      --eol                       Print line ending (LF, CRLF, CR, mixed, none)
+      --mime                      Print content type detected from the head of the file
+      --text-binary               Print whether the file is text or binary
+  -M, --md5                       Print MD5 hash
+  -S, --sha1                      Print SHA-1 hash
+      --sha256                    Print SHA-256 hash
+      --hash strings              Print hashes of the specified algorithms, comma separated (md5, sha1, sha224, sha256, sha384, sha512, sha512-224, sha512-256, sha3-224, sha3-256, sha3-384, sha3-512, crc32, crc32c, crc64-iso, crc64-ecma, adler32, fnv32, fnv32a, fnv64, fnv64a, fnv128, fnv128a)
+      --hmac strings              Print HMACs of the specified algorithms, comma separated (md5, sha1, sha224, sha256, sha384, sha512, sha512-224, sha512-256, sha3-224, sha3-256, sha3-384, sha3-512)
+      --key-file string           Key file for HMAC
+      --tree-hash                 Print Merkle tree hash (SHA-256 of contents for files, hash of all children for directories)
+      --exec-column stringArray   Print output of an external command as a column (NAME='command {}', {} is replaced with the file path)
+      --exec-timeout duration     Timeout for each command of --exec-column (0 is unlimited) (default 30s)
+      --exec-jobs int             Maximum number of commands of --exec-column to run at the same time (default 4)
+      --columns string            Print the specified columns, comma separated, with an option after ':' (e.g. rel,size:human,mtime:unix,sha256:12)
+      --list-columns              List columns available for --columns and their options
+      --include-dir               Include directories
+      --exclude-file              Exclude files
+      --include-root              Include the specified directories themselves (e.g. to print --tree-hash of the whole tree)
+  -l, --level int                 Number of directory level (Default is unlimited)
+      --no-hidden                 Exclude hidden files and directories (names starting with '.')
+      --hidden-only               Print only hidden files and directories, and entries under hidden directories
+      --one-file-system           Do not descend into directories on other file systems
+      --exclude-fstype strings    Skip mount points of the specified file system types, comma separated (e.g. nfs,tmpfs,proc)
+      --archives                  List contents of archives (.zip, .tar, .tar.gz, .tgz) as directories
+      --nested-archives           Also list contents of archives inside archives (requires --archives)
+      --min-level int             Minimum directory level to print (Default is unlimited)
+      --from-file string          Read paths from the specified file instead of walking directories ('-' for stdin, newline or NUL delimited)
+      --base string               Base directory for relative paths of file arguments and --from-file (Default is the parent directory for file arguments, current directory for --from-file)
+  -0, --null                      Terminate each line with NUL instead of newline
+      --escape                    Escape control characters (\n, \t, \xHH, ...) and backslashes in the output
+      --sign string               Append Ed25519 signature of the output with the specified private key file
+      --verify string             Compare the listing with the specified manifest instead of printing it (specify the same flags and --key-file as when the manifest was created)
+      --progress                  Print progress on stderr (only if stderr is a terminal)
+      --timeout duration          Stop listing after the specified duration (e.g. 30m, 0 is unlimited)
+      --checkpoint string         Record the printed entries to the specified file, to resume the listing with --resume
+      --resume                    Skip the entries already printed according to --checkpoint, to append the rest to the previous output
+      --max-read-rate string      Limit the bytes read per second for content columns (e.g. 50M, units are K, M, G and T in 1024)
+      --max-files-per-sec float   Limit the files listed per second (0 is unlimited)
+      --drop-cache                Advise the OS to drop the page cache of files after reading them (Linux only)
+      --profile string            Use the named profile in config files
+      --no-config                 Do not read config files
+  -h, --help                      Help
+`
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_Human(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", strings.Repeat("x", 1000), "")
+	setupFile(t, temp, "b.txt", strings.Repeat("x", 1536), "")
+	setupFile(t, temp, "c.txt", strings.Repeat("x", 3*1024*1024), "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"-s",
+			"--human",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.txt", "1000 B"),
+		line("b.txt", "1.5 KiB"),
+		line("c.txt", "3.0 MiB"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_SI(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", strings.Repeat("x", 999), "")
+	setupFile(t, temp, "b.txt", strings.Repeat("x", 1536), "")
+	setupFile(t, temp, "c.txt", strings.Repeat("x", 3*1024*1024), "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--si",
+			"--precision", "2",
+			"-s",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.txt", "999 B"),
+		line("b.txt", "1.54 kB"),
+		line("c.txt", "3.15 MB"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_HumanAndSI(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"-s",
+			"--human",
+			"--si",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+	assert.Equal(t, "Error: --human and --si cannot be specified at the same time", out.String())
+}
+
+func TestRun_Hash(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "ABCDEFG", "")
+	setupFile(t, temp, "b.txt", "", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"-M",
+			"--hash", "sha512,crc32",
+			"--hash", "adler32",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line(
+			"a.txt",
+			"bb747b3df3130fe1ca4afa93fb7d97c9",
+			"3e78b4cd8884f15622a00526308871c805dae8a9acb0652bf78c977cf5150ca85e0ae8de88be668d13e2542654dbe139afa6c914783f8dd6d70e78b47d41addc",
+			"0e6f94bc",
+			"075b01dd",
+		),
+		line(
+			"b.txt",
+			"d41d8cd98f00b204e9800998ecf8427e",
+			"cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e",
+			"00000000",
+			"00000001",
+		),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_HashUnknown(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--hash", "sha256,xxx",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+	assert.Equal(t, "Error: unknown hash algorithm: xxx", out.String())
+}
+
+func TestRun_Hmac(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "ABCDEFG", "")
+	keyFile, _ := setupFile(t, t.TempDir(), "key", "secret", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--hmac", "sha256",
+			"--key-file", keyFile,
+			"--sha256",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line(
+			"a.txt",
+			"29a146676adcc892b9297c6d7b325c0722115104f9b27b4faf93e10f0c643deb",
+			"e9a92a2ed0d53732ac13b031a27b071814231c8633c9f41844ccba884d482b16",
+		),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_HmacWithoutKey(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--hmac", "sha256",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+	assert.Equal(t, "Error: --key-file is required for --hmac", out.String())
+}
+
+func TestRun_HmacEmptyKey(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+	keyFile, _ := setupFile(t, t.TempDir(), "key", "", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--hmac", "sha256",
+			"--key-file", keyFile,
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+	assert.Equal(t, "Error: key file is empty: "+keyFile, out.String())
+}
+
+func TestRun_VerifyHmac(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "ABCDEFG", "")
+	setupFile(t, temp, "b.txt", "HIJ", "")
+	keyDir := t.TempDir()
+	keyFile, _ := setupFile(t, keyDir, "key", "secret", "")
+
+	manifestOut := new(bytes.Buffer)
+	require.Equal(t, OK, run([]string{temp, "--hmac", "sha256", "--key-file", keyFile}, manifestOut))
+	manifestFile, _ := setupFile(t, keyDir, "manifest.txt", manifestOut.String(), "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--hmac", "sha256",
+			"--key-file", keyFile,
+			"--verify", manifestFile,
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+	assert.Equal(t, "OK\n", out.String())
+}
+
+func TestRun_VerifyHmac_Tampered(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "ABCDEFG", "")
+	setupFile(t, temp, "b.txt", "HIJ", "")
+	keyDir := t.TempDir()
+	keyFile, _ := setupFile(t, keyDir, "key", "secret", "")
+
+	manifestOut := new(bytes.Buffer)
+	require.Equal(t, OK, run([]string{temp, "--hmac", "sha256", "--key-file", keyFile}, manifestOut))
+	manifestFile, _ := setupFile(t, keyDir, "manifest.txt", manifestOut.String(), "")
+
+	// マニフェストの作成後にファイルを改ざん
+	setupFile(t, temp, "a.txt", "XXXXXXX", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--hmac", "sha256",
+			"--key-file", keyFile,
+			"--verify", manifestFile,
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+
+	manifestLines := strings.Split(manifestOut.String(), "\n")
+	assert.Equal(t,
+		"- "+manifestLines[0]+"\n"+
+			"+ a.txt\t"+hmacSha256Hex("secret", "XXXXXXX")+"\n"+
+			"Error: manifest does not match (2 differences)",
+		out.String())
+}
+
+func TestRun_VerifyHmac_OtherKey(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "ABCDEFG", "")
+	keyDir := t.TempDir()
+	keyFile, _ := setupFile(t, keyDir, "key", "secret", "")
+	otherKeyFile, _ := setupFile(t, keyDir, "other", "other", "")
+
+	manifestOut := new(bytes.Buffer)
+	require.Equal(t, OK, run([]string{temp, "--hmac", "sha256", "--key-file", keyFile}, manifestOut))
+	manifestFile, _ := setupFile(t, keyDir, "manifest.txt", manifestOut.String(), "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--hmac", "sha256",
+			"--key-file", otherKeyFile,
+			"--verify", manifestFile,
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+	assert.True(t, strings.HasSuffix(out.String(), "Error: manifest does not match (2 differences)"))
+}
+
+func TestRun_Verify_WithSign(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--verify", filepath.Join(temp, "manifest.txt"),
+			"--sign", filepath.Join(temp, "key"),
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+	assert.Equal(t, "Error: --verify cannot be specified with --sign or --checkpoint", out.String())
+}
+
+func hmacSha256Hex(key string, contents string) string {
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(contents))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestRun_IncludeRoot_TreeHash(t *testing.T) {
+
+	// ARRANGE
+	temp1 := t.TempDir()
+	temp2 := t.TempDir()
+	temp3 := t.TempDir()
+
+	setupFiles(t, temp1)
+	setupFiles(t, temp2)
+	setupFiles(t, temp3)
+	setupFile(t, filepath.Join(temp3, "a", "xxx"), "x.txt", "changed", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp1,
+			temp2,
+			temp3,
+			"--include-root",
+			"--exclude-file",
+			"--tree-hash",
+			"--depth",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+
+	// 同じ内容のツリーは、ルートのハッシュが一致する
+	assert.Equal(t, lines[0], lines[1])
+	assert.NotEqual(t, lines[0], lines[2])
+	assert.Regexp(t, `^\.`+regexp.QuoteMeta(string(filepath.Separator))+`\t[0-9a-f]{64}\t0$`, lines[0])
+}
+
+func TestRun_Sign(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	keyDir := t.TempDir()
+	privateKeyFile := filepath.Join(keyDir, "key")
+	publicKeyFile := filepath.Join(keyDir, "key.pub")
+
+	keygenOut := new(bytes.Buffer)
+	require.Equal(t, OK, run([]string{"keygen", privateKeyFile}, keygenOut))
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"-s",
+			"--sign", privateKeyFile,
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	listing := allLines(
+		line("1.txt", "0"),
+		line(filepath.Join("a", "a.txt"), "1"),
+		line(filepath.Join("a", "b.txt"), "10"),
+		line(filepath.Join("a", "xxx", "x.txt"), "20"),
+		line(filepath.Join("x", "y", "z", "テスト.txt"), "100"),
+	)
+	require.True(t, strings.HasPrefix(out.String(), listing+signaturePrefix))
+
+	manifestFile, _ := setupFile(t, keyDir, "manifest.txt", out.String(), "")
+
+	verifyOut := new(bytes.Buffer)
+	assert.Equal(t, OK, run([]string{"verify-sig", publicKeyFile, manifestFile}, verifyOut))
+	assert.Equal(t, "OK\n", verifyOut.String())
+
+	// 改ざんされた場合はエラー
+	tamperedFile, _ := setupFile(t, keyDir, "tampered.txt", strings.Replace(out.String(), "\t10\n", "\t11\n", 1), "")
+
+	verifyOut = new(bytes.Buffer)
+	assert.Equal(t, NG, run([]string{"verify-sig", publicKeyFile, tamperedFile}, verifyOut))
+	assert.Equal(t, "Error: signature verification failed", verifyOut.String())
+}
+
+func TestRun_Sign_Null(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "A", "")
+
+	keyDir := t.TempDir()
+	privateKeyFile := filepath.Join(keyDir, "key")
+	publicKeyFile := filepath.Join(keyDir, "key.pub")
+
+	require.Equal(t, OK, run([]string{"keygen", privateKeyFile}, new(bytes.Buffer)))
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"-0",
+			"--sign", privateKeyFile,
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	// 署名行も NUL で終わる
+	require.True(t, strings.HasPrefix(out.String(), "a.txt\x00"+signaturePrefix))
+	require.True(t, strings.HasSuffix(out.String(), "\x00"))
+	assert.NotContains(t, out.String(), "\n")
+
+	manifestFile, _ := setupFile(t, keyDir, "manifest.txt", out.String(), "")
+
+	verifyOut := new(bytes.Buffer)
+	assert.Equal(t, OK, run([]string{"verify-sig", publicKeyFile, manifestFile}, verifyOut))
+	assert.Equal(t, "OK\n", verifyOut.String())
+}
+
+func TestRun_SignKeyNotFound(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--sign", filepath.Join(temp, "___"),
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+	assert.Contains(t, out.String(), "Error: ")
+}
+
+func TestRun_KeygenNoArgs(t *testing.T) {
+
+	// ARRANGE
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"keygen",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+	assert.Equal(t, "Usage: filist keygen KEYFILE\n", out.String())
+}
+
+func TestRun_TreeHash(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--include-dir",
+			"--tree-hash",
+			"--sha256",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	hashes := map[string]string{}
+	for _, l := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		items := strings.Split(l, "\t")
+		require.Len(t, items, 3)
+		hashes[items[0]] = items[1]
+
+		if !strings.HasSuffix(items[0], string(filepath.Separator)) {
+			// ファイルは内容のSHA-256と同じ
+			assert.Equal(t, items[2], items[1])
+		}
+	}
+
+	assert.Len(t, hashes, 12)
+	assert.Equal(t, treeHash(t, filepath.Join(temp, "a")), hashes["a"+string(filepath.Separator)])
+	assert.Equal(t, treeHash(t, filepath.Join(temp, "x", "y")), hashes[filepath.Join("x", "y")+string(filepath.Separator)])
+	// 空のディレクトリ
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", hashes[filepath.Join("a", "xxx", "yyy")+string(filepath.Separator)])
+}
+
+func TestRun_FromFile(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	listFile, _ := setupFile(t, t.TempDir(), "list.txt", "a/b.txt\r\nx/y/z/テスト.txt\r\n\r\na\r\n1.txt", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"--from-file", listFile,
+			"--base", temp,
+			"-s",
+			"-a",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("10", filepath.Join(temp, "a", "b.txt")),
+		line("100", filepath.Join(temp, "x", "y", "z", "テスト.txt")),
+		line("0", filepath.Join(temp, "1.txt")),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_FromFile_Null(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("file names cannot contain newlines on Windows")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+	setupFile(t, temp, "new\nline.txt", "", "")
+
+	listFile, _ := setupFile(t, t.TempDir(), "list.txt", "a\x00new\nline.txt\x00"+filepath.Join(temp, "a", "a.txt")+"\x00", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"--from-file", listFile,
+			"--base", temp,
+			"--include-dir",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a"+string(filepath.Separator)),
+		line("new\nline.txt"),
+		line(filepath.Join("a", "a.txt")),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_FromFile_Stdin(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	stdinFile, _ := setupFile(t, t.TempDir(), "stdin.txt", filepath.Join(temp, "a", "a.txt")+"\n"+filepath.Join(temp, "1.txt")+"\n", "")
+	stdin, err := os.Open(stdinFile)
+	require.NoError(t, err)
+	defer stdin.Close()
+
+	orgStdin := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = orgStdin }()
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"--from-file", "-",
+			"--base", filepath.Join(temp, "a"),
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.txt"),
+		line(filepath.Join("..", "1.txt")),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_FromFile_Level(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	listFile, _ := setupFile(t, t.TempDir(), "list.txt", "1.txt\na/a.txt\na/xxx/x.txt\n", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"--from-file", listFile,
+			"--base", temp,
+			"--min-level", "2",
+			"--level", "2",
+			"--depth",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line(filepath.Join("a", "a.txt"), "2"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_FromFile_NoHidden(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+	setupFile(t, filepath.Join(temp, ".h"), "3.txt", "", "")
+
+	listFile, _ := setupFile(t, t.TempDir(), "list.txt", "1.txt\n.h/3.txt\n", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"--from-file", listFile,
+			"--base", temp,
+			"--no-hidden",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("1.txt"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_FromFile_PathNotFound(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	listFile, _ := setupFile(t, t.TempDir(), "list.txt", "1.txt\n___\n", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"--from-file", listFile,
+			"--base", temp,
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+	assert.True(t, strings.HasPrefix(out.String(), line("1.txt")+"Error: "))
+	assert.Contains(t, out.String(), filepath.Join(temp, "___"))
+}
+
+func TestRun_Null(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFiles(t, temp)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"-0",
+			"-s",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := "1.txt\t0\x00" +
+		filepath.Join("a", "a.txt") + "\t1\x00" +
+		filepath.Join("a", "b.txt") + "\t10\x00" +
+		filepath.Join("a", "xxx", "x.txt") + "\t20\x00" +
+		filepath.Join("x", "y", "z", "テスト.txt") + "\t100\x00"
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_Escape(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("file names cannot contain control characters on Windows")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a\nb.txt", "", "")
+	setupFile(t, temp, "c\td\\e.txt", "", "")
+	setupFile(t, temp, "f\x1bg.txt", "", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--escape",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line(`a\nb.txt`),
+		line(`c\td\\e.txt`),
+		line(`f\x1bg.txt`),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_Count(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "hello world\n", "")
+	setupFile(t, temp, "b.txt", "hello world\r\nテスト", "")
+	setupFile(t, temp, "c.bin", "\x00\x01\x02", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--lines",
+			"--words",
+			"--chars",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.txt", "1", "2", "12"),
+		line("b.txt", "2", "3", "15"),
+		line("c.bin", "-", "-", "-"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_Count_UTF16(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	// "hello world\r\nテスト" のBOM付きUTF-16LE
+	setupFile(t, temp, "a.txt", "\xff\xfeh\x00e\x00l\x00l\x00o\x00 \x00w\x00o\x00r\x00l\x00d\x00\r\x00\n\x00\xc6\x30\xb9\x30\xc8\x30", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--text-binary",
+			"--encoding",
+			"--lines",
+			"--words",
+			"--chars",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	// テキストと判定されたものは、行数なども数える
+	expected := allLines(
+		line("a.txt", "text", "UTF-16LE", "2", "3", "15"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_Encoding(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "abc\n", "")
+	setupFile(t, temp, "b.txt", "\xef\xbb\xbfテスト\r\n", "")
+	setupFile(t, temp, "c.txt", "テスト\r\nabc\n", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--encoding",
+			"--eol",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.txt", "ASCII", "LF"),
+		line("b.txt", "UTF-8-BOM", "CRLF"),
+		line("c.txt", "UTF-8", "mixed"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_Mime(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "hello\n", "")
+	setupFile(t, temp, "b.txt", "%PDF-1.7\n", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--mime",
+			"--text-binary",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.txt", "text/plain; charset=utf-8", "text"),
+		line("b.txt", "application/pdf", "binary"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_ExecColumn(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on this platform")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "A\n", "")
+	setupFile(t, filepath.Join(temp, "b"), "b.txt", "BB\n", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--size",
+			"--exec-column", "head=head -c 1 {}",
+			"--exec-column", `name=sh -c 'basename "$1"' sh {}`,
+			"--include-dir",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.txt", "2", "A", "a.txt"),
+		line("b"+string(filepath.Separator), "", "", ""),
+		line(filepath.Join("b", "b.txt"), "3", "B", "b.txt"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_ExecColumn_Columns(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on this platform")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "A\nB\n", "")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--columns", "wc,rel",
+			"--exec-column", "wc=sh -c 'wc -l < \"$1\"' sh {}",
+			"--exec-column", "head=head -c 1 {}",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	// --columns に含まれる外部コマンドの列はその位置に、含まれないものは --exec-column の位置に表示
+	expected := allLines(
+		line("2", "a.txt", "A"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_ExecColumn_Invalid(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--exec-column", "head -c 1 {}",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+
+	assert.Equal(t, "Error: invalid --exec-column (NAME='command {}'): head -c 1 {}", out.String())
+}
+
+func TestRun_ExecColumn_BuiltinName(t *testing.T) {
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"rel", []string{"--exec-column", "rel=echo pwned"}},
+		{"sha256", []string{"--exec-column", "sha256=echo fake", "--sha256"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ARRANGE
+			temp := t.TempDir()
+			setupFile(t, temp, "a.txt", "A\n", "")
+
+			out := new(bytes.Buffer)
+
+			// ACT
+			exitCode := run(append([]string{temp}, tt.args...), out)
+
+			// ASSERT
+			require.Equal(t, NG, exitCode)
+
+			assert.Equal(t, "Error: column already exists: "+tt.name, out.String())
+		})
+	}
+}
+
+func TestRun_Columns(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", strings.Repeat("x", 2048), "2021-02-03T04:05:06")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--columns", "size:human,mtime:unix,rel,sha256:12",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("2.0 KiB", "1612325106", "a.txt", "1d1801f753cc"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_Columns_WithFlags(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "ABC", "2021-02-03T04:05:06")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"-s",
+			"--columns", "rel,md5:4",
+			"--depth",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("3", "a.txt", "902f", "1"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_Columns_Unknown(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--columns", "rel,xxx",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+
+	assert.Equal(t, "Error: unknown column: xxx", out.String())
+}
+
+func TestRun_ListColumns(t *testing.T) {
+
+	// ARRANGE
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"--list-columns",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	lines := strings.Split(out.String(), "\n")
+	assert.Regexp(t, `^NAME +OPTIONS +DESCRIPTION$`, lines[0])
+	assert.Regexp(t, `^rel +Relative path$`, lines[1])
+	assert.Regexp(t, `(?m)^size +bytes\|human\|si +File size$`, out.String())
+	assert.Regexp(t, `(?m)^sha256 +N \(first N characters\) +Hash \(sha256\)$`, out.String())
+}
+
+func TestRun_ListColumns_ExecColumn(t *testing.T) {
+
+	// ARRANGE
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			"--list-columns",
+			"--exec-column", "wc=wc -l {}",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	assert.Regexp(t, `(?m)^wc +Output of command: wc -l \{\}$`, out.String())
+}
+
+func TestRun_Config(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFile(t, temp, "a.txt", "ABC", "2021-02-03T04:05:06")
+	setupFile(t, temp, ".hidden", "", "")
+
+	configHome := t.TempDir()
+	setupFile(t, filepath.Join(configHome, "filist"), "config", "size\nno-hidden = true\n", "")
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	// プロジェクトの設定ファイルは、親ディレクトリにあっても読む
+	project := t.TempDir()
+	setupFile(t, project, ".filist", "# project\nmd5\n", "")
+	setupDir(t, filepath.Join(project, "sub"))
+	t.Chdir(filepath.Join(project, "sub"))
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--level", "1",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.txt", "3", "902fbdd2b1df0c4f70b4a5d23525e932"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_Config_CommandLinePrecedence(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFile(t, temp, "a.txt", strings.Repeat("x", 2048), "")
+
+	configHome := t.TempDir()
+	setupFile(t, filepath.Join(configHome, "filist"), "config", "columns = rel,size\nhuman\nprecision = 2\n", "")
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--si",
+			"--columns", "size",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("2.05 kB"),
+	)
+	assert.Equal(t, expected, out.String())
+
+	// 列の名前と同じオプションでも、設定ファイルの列は使わない
+	out.Reset()
+	exitCode = run(
+		[]string{
+			temp,
+			"-M",
+		},
+		out,
+	)
+
+	require.Equal(t, OK, exitCode)
+
+	expected = allLines(
+		line("a.txt", "cfb767f225d58469c5de3632a8803958"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_Config_ProjectUserOnly(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"exec-column", "exec-column = pwn=touch PWNED"},
+		{"exec-timeout", "exec-timeout = 1s"},
+		{"exec-jobs", "exec-jobs = 8"},
+		{"checkpoint", "checkpoint = victim.txt"},
+		{"resume", "resume"},
+		{"key-file", "key-file = key.txt"},
+		{"sign", "sign = key.pem"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ARRANGE
+			temp := t.TempDir()
+			setupFile(t, temp, "a.txt", "ABC", "")
+
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+			// プロファイルの中に書いても指定できない
+			project := t.TempDir()
+			projectConfig, _ := setupFile(t, project, ".filist", "size\n[audit]\n"+tt.value+"\n", "")
+			setupFile(t, project, "victim.txt", "keep", "")
+			t.Chdir(project)
+
+			out := new(bytes.Buffer)
+
+			// ACT
+			exitCode := run(
+				[]string{
+					temp,
+				},
+				out,
+			)
+
+			// ASSERT
+			require.Equal(t, NG, exitCode)
+
+			assert.Equal(t, "Error: "+projectConfig+":3: "+tt.name+" cannot be specified in project config file (use user config file or command line)", out.String())
+			assert.NoFileExists(t, filepath.Join(project, "PWNED"))
+			data, err := os.ReadFile(filepath.Join(project, "victim.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "keep", string(data))
+		})
+	}
+}
+
+func TestRun_Config_UserOnlyInUserConfig(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on this platform")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFile(t, temp, "a.txt", "ABC", "")
+
+	// ユーザの設定ファイルには書ける
+	configHome := t.TempDir()
+	setupFile(t, filepath.Join(configHome, "filist"), "config", "exec-column = head=head -c 1 {}\nexec-jobs = 2\n", "")
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.txt", "A"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_Profile(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFile(t, temp, "a.txt", "ABC", "")
+
+	configHome := t.TempDir()
+	setupFile(t, filepath.Join(configHome, "filist"), "config", "columns = rel\n\n[audit]\ncolumns = rel,md5:8,size\n", "")
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--profile", "audit",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.txt", "902fbdd2", "3"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_ProfileNotFound(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--profile", "audit",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+
+	assert.Equal(t, "Error: profile not found: audit", out.String())
+}
+
+func TestRun_Config_UnknownOption(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	configHome := t.TempDir()
+	configFile, _ := setupFile(t, filepath.Join(configHome, "filist"), "config", "size\nxxx = 1\n", "")
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+
+	assert.Equal(t, "Error: "+configFile+":2: unknown option: xxx", out.String())
+}
+
+func TestRun_NoConfig(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFile(t, temp, "a.txt", "ABC", "")
+
+	configHome := t.TempDir()
+	setupFile(t, filepath.Join(configHome, "filist"), "config", "size\n", "")
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--no-config",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.txt"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_Timeout(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on this platform")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "A", "")
+	setupFile(t, temp, "b.txt", "B", "")
+
+	stderr := new(bytes.Buffer)
+	errorOut = stderr
+	defer func() { errorOut = os.Stderr }()
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--exec-column", `x=sh -c 'case "$1" in *b.txt) exec sleep 10;; esac; echo ok' sh {}`,
+			"--timeout", "1s",
+			"--null",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+
+	// 出力されるのは完全な行だけで、中断の報告は標準エラーに出す
+	assert.Equal(t, "a.txt\tok\x00", out.String())
+	assert.Equal(t, "Error: timed out after 1s (last completed: "+filepath.Join(temp, "a.txt")+")\n", stderr.String())
+}
+
+func TestInterruptedMessage(t *testing.T) {
+
+	// ARRANGE
+	canceled, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	timedOut, cancel := context.WithTimeout(t.Context(), -1)
+	defer cancel()
+
+	// ACT & ASSERT
+	assert.Equal(t, "interrupted (last completed: a.txt)", interruptedMessage(canceled, 0, "a.txt"))
+	assert.Equal(t, "interrupted (no entries completed)", interruptedMessage(canceled, 0, ""))
+	assert.Equal(t, "timed out after 1m0s (last completed: a.txt)", interruptedMessage(timedOut, time.Minute, "a.txt"))
+}
+
+func TestRun_Checkpoint(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "A", "")
+	setupFile(t, filepath.Join(temp, "b"), "b.txt", "B", "")
+	setupFile(t, temp, "c.txt", "C", "")
+
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint")
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--checkpoint", checkpointFile,
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.txt"),
+		line(filepath.Join("b", "b.txt")),
+		line("c.txt"),
+	)
+	assert.Equal(t, expected, out.String())
+
+	count, path, _, err := readCheckpoint(checkpointFile)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, filepath.Join(temp, "c.txt"), path)
+}
+
+func TestRun_Resume(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "A", "")
+	setupFile(t, filepath.Join(temp, "b"), "b.txt", "B", "")
+	setupFile(t, temp, "c.txt", "C", "")
+
+	// a.txt まで出力したところで中断した状態
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint")
+	options := outputOptions([]string{temp}, nil, []string{"rel", "md5"}, false, false)
+	c, err := openCheckpoint(checkpointFile, options, false)
+	require.NoError(t, err)
+	require.NoError(t, c.record(filepath.Join(temp, "a.txt")))
+	require.NoError(t, c.close())
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--md5",
+			"--checkpoint", checkpointFile,
+			"--resume",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line(filepath.Join("b", "b.txt"), "9d5ed678fe57bcca610140957afab571"),
+		line("c.txt", "0d61f8370cad1d412f80b84d143e1257"),
+	)
+	assert.Equal(t, expected, out.String())
+
+	count, path, _, err := readCheckpoint(checkpointFile)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, filepath.Join(temp, "c.txt"), path)
+}
+
+func TestRun_Resume_Append(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on this platform")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "A", "")
+	setupFile(t, filepath.Join(temp, "b"), "b.txt", "B", "")
+	setupFile(t, temp, "c.txt", "C", "")
+	setupFile(t, temp, "d.txt", "D", "")
+
+	// このファイルがある間は、c.txt のコマンドが終わらずにタイムアウトする
+	slow, _ := setupFile(t, t.TempDir(), "slow", "", "")
+
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint")
+	arguments := []string{
+		temp,
+		"--md5",
+		"--exec-column", `x=sh -c 'case "$1" in *c.txt) [ -e "$2" ] && exec sleep 10;; esac; echo ok' sh {} '` + slow + `'`,
+		"--checkpoint", checkpointFile,
+	}
+
+	stderr := new(bytes.Buffer)
+	errorOut = stderr
+	defer func() { errorOut = os.Stderr }()
+
+	// 中断されるまでの出力に、再開後の出力を追記する (> sha256.txt、--resume >> sha256.txt と同じ)
+	manifest := new(bytes.Buffer)
+	exitCode := run(append(slices.Clone(arguments), "--timeout", "1s"), manifest)
+	require.Equal(t, NG, exitCode)
+	require.Contains(t, stderr.String(), "Error: timed out after 1s")
+	require.Equal(t, allLines(
+		line("a.txt", "7fc56270e7a70fa81a5935b72eacbe29", "ok"),
+		line(filepath.Join("b", "b.txt"), "9d5ed678fe57bcca610140957afab571", "ok"),
+	), manifest.String())
+
+	require.NoError(t, os.Remove(slow))
+
+	// ACT
+	exitCode = run(append(slices.Clone(arguments), "--resume"), manifest)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	// 中断せずに出力した場合と同じになる
+	uninterrupted := new(bytes.Buffer)
+	arguments[len(arguments)-1] = filepath.Join(t.TempDir(), "checkpoint")
+	require.Equal(t, OK, run(arguments, uninterrupted))
+
+	expected := allLines(
+		line("a.txt", "7fc56270e7a70fa81a5935b72eacbe29", "ok"),
+		line(filepath.Join("b", "b.txt"), "9d5ed678fe57bcca610140957afab571", "ok"),
+		line("c.txt", "0d61f8370cad1d412f80b84d143e1257", "ok"),
+		line("d.txt", "f623e75af30e62bbd73d6df5b50bb7b5", "ok"),
+	)
+	assert.Equal(t, expected, uninterrupted.String())
+	assert.Equal(t, uninterrupted.String(), manifest.String())
+}
+
+func TestRun_Resume_Mismatch(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "A", "")
+	setupFile(t, temp, "c.txt", "C", "")
+
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint")
+	c, err := openCheckpoint(checkpointFile, outputOptions([]string{temp}, nil, []string{"rel"}, false, false), false)
+	require.NoError(t, err)
+	require.NoError(t, c.record(filepath.Join(temp, "b.txt")))
+	require.NoError(t, c.close())
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--checkpoint", checkpointFile,
+			"--resume",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+
+	assert.Equal(t, "Error: checkpoint does not match at entry 1: "+filepath.Join(temp, "b.txt")+" was recorded, but found "+filepath.Join(temp, "a.txt"), out.String())
+}
+
+func TestRun_Resume_OptionsMismatch(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "A", "")
+	setupFile(t, temp, "c.txt", "C", "")
+
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint")
+	require.Equal(t, OK, run([]string{temp, "--checkpoint", checkpointFile}, new(bytes.Buffer)))
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"columns", []string{temp, "-M"}},
+		{"null", []string{temp, "-0"}},
+		{"escape", []string{temp, "--escape"}},
+		{"arguments", []string{temp, temp}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			out := new(bytes.Buffer)
+
+			// ACT
+			exitCode := run(append(tt.args, "--checkpoint", checkpointFile, "--resume"), out)
+
+			// ASSERT
+			require.Equal(t, NG, exitCode)
+
+			// 列の構成などが違う出力を追記しない
+			assert.Equal(t, "Error: checkpoint was recorded with different arguments or output options: "+checkpointFile, out.String())
+		})
+	}
+}
+
+func TestRun_Resume_WithoutCheckpoint(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--resume",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+
+	assert.Equal(t, "Error: --checkpoint is required for --resume", out.String())
+}
+
+func TestRun_Throttle(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	setupFile(t, temp, "a.txt", "ABC", "")
+	setupFile(t, temp, "b.txt", "DEF", "")
+
+	args := []string{
+		temp,
+		"--columns", "rel,md5:4",
+		"--max-read-rate", "1K",
+		"--max-files-per-sec", "100",
+	}
+	if filist.DropCacheSupported {
+		args = append(args, "--drop-cache")
+	}
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(args, out)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	expected := allLines(
+		line("a.txt", "902f"),
+		line("b.txt", "822d"),
+	)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRun_MaxReadRate_Invalid(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--max-read-rate", "50X",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+
+	assert.Equal(t, "Error: invalid --max-read-rate (e.g. 50M): 50X", out.String())
+}
+
+func TestRun_MaxFilesPerSec_Negative(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--max-files-per-sec", "-1",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+
+	assert.Equal(t, "Error: --max-files-per-sec must be 0 or more", out.String())
+}
+
+func TestRun_DropCache_Unsupported(t *testing.T) {
+
+	if filist.DropCacheSupported {
+		t.Skip("--drop-cache is supported on this platform")
+	}
+
+	// ARRANGE
+	temp := t.TempDir()
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--drop-cache",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, NG, exitCode)
+
+	assert.Equal(t, "Error: --drop-cache is not supported on this platform", out.String())
+}
+
+func TestParseRate(t *testing.T) {
+
+	tests := []struct {
+		rate     string
+		expected int64
+	}{
+		{"", 0},
+		{"100", 100},
+		{"100B", 100},
+		{"50M", 50 * 1024 * 1024},
+		{"50m", 50 * 1024 * 1024},
+		{"50MB", 50 * 1024 * 1024},
+		{"50MiB", 50 * 1024 * 1024},
+		{"1.5K", 1536},
+		{"2G", 2 * 1024 * 1024 * 1024},
+		{"1T", 1024 * 1024 * 1024 * 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rate, func(t *testing.T) {
+
+			// ACT
+			result, err := parseRate(tt.rate)
+
+			// ASSERT
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestParseRate_Invalid(t *testing.T) {
+
+	for _, rate := range []string{"M", "0", "0.5", "0.0001K", "-1M", "50X", "50iB", "NaN", "Inf", "10000000T"} {
+		t.Run(rate, func(t *testing.T) {
+
+			// ACT
+			_, err := parseRate(rate)
+
+			// ASSERT
+			assert.EqualError(t, err, "invalid --max-read-rate (e.g. 50M): "+rate)
+		})
+	}
+}
+
+func setupDir(t *testing.T, dir string) {
+
+	err := os.MkdirAll(dir, 0777)
+	require.NoError(t, err)
+}
+
+func setupFile(t *testing.T, dir string, name string, contents string, mtimeStr string) (string, fs.FileInfo) {
+
+	setupDir(t, dir)
+
+	filePath := filepath.Join(dir, name)
+
+	file, err := os.Create(filePath)
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, err = file.Write([]byte(contents))
+	require.NoError(t, err)
 
 	mtime := time.Now()
 	if mtimeStr != "" {
@@ -836,3 +3393,91 @@ func line(items ...string) string {
 func allLines(lines ...string) string {
 	return strings.Join(lines, "")
 }
+
+func createZip(t *testing.T, files map[string]string) []byte {
+
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+
+	for _, name := range sortedKeys(files) {
+		f, err := w.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		})
+		require.NoError(t, err)
+
+		_, err = io.WriteString(f, files[name])
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func createTar(t *testing.T, files map[string]string) []byte {
+
+	buf := new(bytes.Buffer)
+	w := tar.NewWriter(buf)
+
+	for _, name := range sortedKeys(files) {
+		writeTarHeader(t, w, &tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(files[name])),
+			ModTime:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		}, files[name])
+	}
+
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func writeTarHeader(t *testing.T, w *tar.Writer, header *tar.Header, contents string) {
+
+	require.NoError(t, w.WriteHeader(header))
+
+	_, err := io.WriteString(w, contents)
+	require.NoError(t, err)
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func writeArchiveFile(t *testing.T, filePath string, data []byte) {
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0777))
+	require.NoError(t, os.WriteFile(filePath, data, 0666))
+}
+
+func sortedKeys(m map[string]string) []string {
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func treeHash(t *testing.T, dir string) string {
+
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+
+	result, err := filist.TreeHashColumn().Value(&filist.Entry{Path: dir, BaseDir: filepath.Dir(dir), Info: info})
+	require.NoError(t, err)
+
+	return result
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/onozaty/filist/filist"
+)
+
+// 進捗の表示先
+var progressOut io.Writer = os.Stderr
+
+const (
+	progressInterval = 500 * time.Millisecond
+	// 進捗に表示するパスの最大の文字数 (超える場合は先頭を省略)
+	progressPathLength = 40
+)
+
+// progressReporter 進捗の状態を一定間隔で1行に上書き表示する
+type progressReporter struct {
+	out      io.Writer
+	progress *filist.Progress
+	start    time.Time
+	// 前回表示した行の文字数 (短くなった場合に残りを空白で消すため)
+	width int
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func startProgress(out io.Writer, progress *filist.Progress, interval time.Duration) *progressReporter {
+
+	r := &progressReporter{
+		out:      out,
+		progress: progress,
+		start:    time.Now(),
+		done:     make(chan struct{}),
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.print()
+			case <-r.done:
+				return
+			}
+		}
+	}()
+
+	return r
+}
+
+// stop 最終的な状態を表示して終了する
+func (r *progressReporter) stop() {
+
+	close(r.done)
+	r.wg.Wait()
+
+	r.print()
+	fmt.Fprintln(r.out)
+}
+
+func (r *progressReporter) print() {
+
+	line := formatProgress(r.progress.Status(), time.Since(r.start))
+
+	width := utf8.RuneCountInString(line)
+	padding := ""
+	if width < r.width {
+		padding = strings.Repeat(" ", r.width-width)
+	}
+	r.width = width
+
+	fmt.Fprintf(r.out, "\r%s%s", line, padding)
+}
+
+func formatProgress(status filist.ProgressStatus, elapsed time.Duration) string {
+
+	var items []string
+
+	if status.TotalKnown {
+		items = append(items, fmt.Sprintf("%d/%d files", status.Files, status.TotalFiles))
+	} else {
+		items = append(items, fmt.Sprintf("%d files", status.Files))
+	}
+
+	items = append(items, filist.FormatSize(status.Bytes, filist.SizeFormatIEC, 1))
+
+	seconds := elapsed.Seconds()
+	if seconds > 0 {
+		items = append(items, filist.FormatSize(int64(float64(status.Bytes)/seconds), filist.SizeFormatIEC, 1)+"/s")
+	}
+
+	if eta, ok := estimateRemaining(status, elapsed); ok {
+		items = append(items, "ETA "+eta.String())
+	}
+
+	if status.Path != "" {
+		items = append(items, shortenPath(status.Path))
+	}
+
+	return strings.Join(items, "  ")
+}
+
+// estimateRemaining 事前の走査で求めた全体に対する割合から、残り時間を見積もる
+// 内容を読み込んでいればバイト数、読み込んでいなければファイル数の割合を使う
+func estimateRemaining(status filist.ProgressStatus, elapsed time.Duration) (time.Duration, bool) {
+
+	if !status.TotalKnown {
+		return 0, false
+	}
+
+	var ratio float64
+	switch {
+	case status.Bytes > 0 && status.TotalBytes > 0:
+		ratio = float64(status.Bytes) / float64(status.TotalBytes)
+	case status.Files > 0 && status.TotalFiles > 0:
+		ratio = float64(status.Files) / float64(status.TotalFiles)
+	default:
+		return 0, false
+	}
+
+	if ratio >= 1 {
+		return 0, true
+	}
+
+	remaining := time.Duration(float64(elapsed) * (1 - ratio) / ratio)
+	return remaining.Round(time.Second), true
+}
+
+func shortenPath(path string) string {
+
+	runes := []rune(path)
+	if len(runes) <= progressPathLength {
+		return path
+	}
+
+	return "..." + string(runes[len(runes)-(progressPathLength-3):])
+}
+
+// isTerminal 出力先が端末か
+func isTerminal(w io.Writer) bool {
+
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// prescan 進捗に全体のファイル数、サイズを設定するため、列の値を計算せずに走査する
+func prescan(ctx context.Context, walker filist.Walker, dirs []string, paths []string, progress *filist.Progress) {
+
+	walker.Progress = nil
+
+	var files, bytes int64
+	count := func(entry *filist.Entry) error {
+		if !entry.Info.IsDir() {
+			files++
+			if entry.Info.Mode().IsRegular() {
+				bytes += entry.Info.Size()
+			}
+		}
+		return nil
+	}
+
+	if err := walker.Walk(ctx, dirs, count); err != nil {
+		// 本来の走査でエラーになるので、ここでは全体が不明なままとする
+		return
+	}
+	if err := walker.WalkPaths(ctx, paths, count); err != nil {
+		return
+	}
+
+	progress.SetTotal(files, bytes)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onozaty/filist/filist"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatProgress(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		status   filist.ProgressStatus
+		expected string
+	}{
+		{
+			"without total",
+			filist.ProgressStatus{Files: 3, Bytes: 2048, Path: "a.txt"},
+			"3 files  2.0 KiB  1.0 KiB/s  a.txt",
+		},
+		{
+			"bytes",
+			filist.ProgressStatus{Files: 3, Bytes: 2048, Path: "a.txt", TotalFiles: 10, TotalBytes: 8192, TotalKnown: true},
+			"3/10 files  2.0 KiB  1.0 KiB/s  ETA 6s  a.txt",
+		},
+		{
+			"files",
+			filist.ProgressStatus{Files: 1, TotalFiles: 4, TotalKnown: true},
+			"1/4 files  0 B  0 B/s  ETA 6s",
+		},
+		{
+			"long path",
+			filist.ProgressStatus{Files: 1, Path: strings.Repeat("a", 30) + "/" + strings.Repeat("b", 30)},
+			"1 files  0 B  0 B/s  ..." + strings.Repeat("a", 6) + "/" + strings.Repeat("b", 30),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// ACT
+			result := formatProgress(tt.status, 2*time.Second)
+
+			// ASSERT
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestProgressReporter(t *testing.T) {
+
+	// ARRANGE
+	out := new(bytes.Buffer)
+	progress := filist.NewProgress()
+	progress.Write(make([]byte, 10))
+
+	// ACT
+	reporter := startProgress(out, progress, time.Hour)
+	reporter.stop()
+
+	// ASSERT
+	assert.Regexp(t, `^\r0 files  10 B(  [0-9.]+ [kKMGTPE]?i?B/s)?\n$`, out.String())
+}
+
+func TestProgressReporter_Padding(t *testing.T) {
+
+	// ARRANGE
+	out := new(bytes.Buffer)
+	reporter := &progressReporter{out: out, progress: filist.NewProgress(), width: 30}
+
+	// ACT
+	reporter.print()
+
+	// ASSERT
+	line := strings.TrimPrefix(out.String(), "\r")
+	assert.Len(t, line, 30)
+	assert.True(t, strings.HasPrefix(line, "0 files  0 B"))
+	assert.Equal(t, len(strings.TrimRight(line, " ")), reporter.width)
+}
+
+func TestIsTerminal(t *testing.T) {
+
+	// ARRANGE
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	// ACT & ASSERT
+	assert.False(t, isTerminal(f))
+	assert.False(t, isTerminal(new(bytes.Buffer)))
+}
+
+func TestRun_Progress_NotTerminal(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	setupFile(t, temp, "a.txt", "ABC", "")
+
+	stderr := new(bytes.Buffer)
+	progressOut = stderr
+	defer func() { progressOut = os.Stderr }()
+
+	out := new(bytes.Buffer)
+
+	// ACT
+	exitCode := run(
+		[]string{
+			temp,
+			"--progress",
+		},
+		out,
+	)
+
+	// ASSERT
+	require.Equal(t, OK, exitCode)
+
+	assert.Equal(t, allLines(line("a.txt")), out.String())
+	assert.Empty(t, stderr.String())
+}
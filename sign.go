@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// 一覧の末尾に付与する署名行の接頭辞
+const signaturePrefix = "# ed25519-signature: "
+
+// 一覧はストリームで出力するため、SHA-512で事前ハッシュするEd25519ph形式で署名する
+var signatureOptions = &ed25519.Options{Hash: crypto.SHA512}
+
+// signer 出力した内容を全てハッシュに取り込み、最後に署名行を付与する
+type signer struct {
+	out        io.Writer
+	privateKey ed25519.PrivateKey
+	digest     hash.Hash
+	// 署名行の終端 (一覧の各行と同じ)
+	terminator string
+}
+
+func newSigner(out io.Writer, privateKey ed25519.PrivateKey, nullTerminated bool) *signer {
+
+	terminator := "\n"
+	if nullTerminated {
+		terminator = "\x00"
+	}
+
+	return &signer{
+		out:        out,
+		privateKey: privateKey,
+		digest:     sha512.New(),
+		terminator: terminator,
+	}
+}
+
+func (s *signer) Write(p []byte) (int, error) {
+
+	n, err := s.out.Write(p)
+	s.digest.Write(p[:n])
+
+	return n, err
+}
+
+func (s *signer) writeSignature() error {
+
+	signature, err := s.privateKey.Sign(nil, s.digest.Sum(nil), signatureOptions)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(s.out, "%s%s%s", signaturePrefix, base64.StdEncoding.EncodeToString(signature), s.terminator)
+	return err
+}
+
+// splitSignature マニフェストを、一覧の内容と末尾の署名行の値に分ける
+func splitSignature(manifest []byte) ([]byte, []byte, bool) {
+
+	index := bytes.LastIndex(manifest, []byte(signaturePrefix))
+	if index == -1 || (index > 0 && manifest[index-1] != '\n' && manifest[index-1] != 0) {
+		return manifest, nil, false
+	}
+
+	return manifest[:index], bytes.TrimRight(manifest[index+len(signaturePrefix):], "\r\n\x00"), true
+}
+
+func verifyManifest(manifest []byte, publicKey ed25519.PublicKey) error {
+
+	content, encoded, ok := splitSignature(manifest)
+	if !ok {
+		return errors.New("signature not found")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+
+	digest := sha512.Sum512(content)
+	if err := ed25519.VerifyWithOptions(publicKey, digest[:], signature, signatureOptions); err != nil {
+		return errors.New("signature verification failed")
+	}
+
+	return nil
+}
+
+func runKeygen(arguments []string, out io.Writer) int {
+
+	if len(arguments) != 1 {
+		fmt.Fprint(out, "Usage: filist keygen KEYFILE\n")
+		return NG
+	}
+
+	privateKeyFile := arguments[0]
+	publicKeyFile := privateKeyFile + ".pub"
+
+	if err := generateKeys(privateKeyFile, publicKeyFile); err != nil {
+		fmt.Fprintf(out, "Error: %v", err)
+		return NG
+	}
+
+	fmt.Fprintf(out, "Private key: %s\nPublic key: %s\n", privateKeyFile, publicKeyFile)
+	return OK
+}
+
+func runVerifySig(arguments []string, out io.Writer) int {
+
+	if len(arguments) != 2 {
+		fmt.Fprint(out, "Usage: filist verify-sig PUBKEYFILE MANIFEST\n")
+		return NG
+	}
+
+	publicKey, err := loadPublicKey(arguments[0])
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v", err)
+		return NG
+	}
+
+	manifest, err := os.ReadFile(arguments[1])
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v", err)
+		return NG
+	}
+
+	if err := verifyManifest(manifest, publicKey); err != nil {
+		fmt.Fprintf(out, "Error: %v", err)
+		return NG
+	}
+
+	fmt.Fprintln(out, "OK")
+	return OK
+}
+
+func generateKeys(privateKeyFile string, publicKeyFile string) error {
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	privateDer, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	publicDer, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	if err := writePem(privateKeyFile, "PRIVATE KEY", privateDer, 0600); err != nil {
+		return err
+	}
+
+	return writePem(publicKeyFile, "PUBLIC KEY", publicDer, 0644)
+}
+
+func writePem(pemFile string, blockType string, der []byte, perm os.FileMode) error {
+
+	// 既存の鍵を誤って上書きしないように
+	f, err := os.OpenFile(pemFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return pem.Encode(f, &pem.Block{Type: blockType, Bytes: der})
+}
+
+func loadPrivateKey(privateKeyFile string) (ed25519.PrivateKey, error) {
+
+	der, err := readPem(privateKeyFile, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an Ed25519 private key: %s", privateKeyFile)
+	}
+
+	return privateKey, nil
+}
+
+func loadPublicKey(publicKeyFile string) (ed25519.PublicKey, error) {
+
+	der, err := readPem(publicKeyFile, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an Ed25519 public key: %s", publicKeyFile)
+	}
+
+	return publicKey, nil
+}
+
+func readPem(pemFile string, blockType string) ([]byte, error) {
+
+	data, err := os.ReadFile(pemFile)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s not found: %s", blockType, pemFile)
+	}
+
+	return block.Bytes, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+
+	// ARRANGE
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	out := new(bytes.Buffer)
+	signer := newSigner(out, privateKey, false)
+
+	// ACT
+	fmt.Fprintln(signer, "a.txt")
+	fmt.Fprintln(signer, "b.txt")
+	err = signer.writeSignature()
+
+	// ASSERT
+	require.NoError(t, err)
+
+	lines := strings.Split(out.String(), "\n")
+	assert.Equal(t, "a.txt", lines[0])
+	assert.Equal(t, "b.txt", lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], signaturePrefix))
+	assert.Equal(t, "", lines[3])
+
+	assert.NoError(t, verifyManifest(out.Bytes(), publicKey))
+}
+
+func TestSigner_null(t *testing.T) {
+
+	// ARRANGE
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	out := new(bytes.Buffer)
+	signer := newSigner(out, privateKey, true)
+
+	// ACT
+	fmt.Fprint(signer, "a.txt\x00")
+	fmt.Fprint(signer, "b.txt\x00")
+	err = signer.writeSignature()
+
+	// ASSERT
+	require.NoError(t, err)
+
+	// 署名行も一覧の各行と同じく NUL で終わる
+	records := strings.Split(out.String(), "\x00")
+	assert.Equal(t, "a.txt", records[0])
+	assert.Equal(t, "b.txt", records[1])
+	assert.True(t, strings.HasPrefix(records[2], signaturePrefix))
+	assert.NotContains(t, records[2], "\n")
+	assert.Equal(t, "", records[3])
+
+	assert.NoError(t, verifyManifest(out.Bytes(), publicKey))
+}
+
+func TestVerifyManifest_tampered(t *testing.T) {
+
+	// ARRANGE
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	out := new(bytes.Buffer)
+	signer := newSigner(out, privateKey, false)
+	fmt.Fprintln(signer, "a.txt")
+	require.NoError(t, signer.writeSignature())
+
+	manifest := bytes.Replace(out.Bytes(), []byte("a.txt"), []byte("b.txt"), 1)
+
+	// ACT
+	err = verifyManifest(manifest, publicKey)
+
+	// ASSERT
+	require.EqualError(t, err, "signature verification failed")
+}
+
+func TestVerifyManifest_otherKey(t *testing.T) {
+
+	// ARRANGE
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	out := new(bytes.Buffer)
+	signer := newSigner(out, privateKey, false)
+	fmt.Fprintln(signer, "a.txt")
+	require.NoError(t, signer.writeSignature())
+
+	// ACT
+	err = verifyManifest(out.Bytes(), otherPublicKey)
+
+	// ASSERT
+	require.EqualError(t, err, "signature verification failed")
+}
+
+func TestVerifyManifest_noSignature(t *testing.T) {
+
+	// ARRANGE
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	// ACT
+	err = verifyManifest([]byte("a.txt\n"), publicKey)
+
+	// ASSERT
+	require.EqualError(t, err, "signature not found")
+}
+
+func TestGenerateKeys(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	privateKeyFile := filepath.Join(temp, "key")
+	publicKeyFile := filepath.Join(temp, "key.pub")
+
+	// ACT
+	err := generateKeys(privateKeyFile, publicKeyFile)
+
+	// ASSERT
+	require.NoError(t, err)
+
+	privateKey, err := loadPrivateKey(privateKeyFile)
+	require.NoError(t, err)
+	publicKey, err := loadPublicKey(publicKeyFile)
+	require.NoError(t, err)
+
+	assert.Equal(t, privateKey.Public(), publicKey)
+}
+
+func TestGenerateKeys_exists(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	privateKeyFile, _ := setupFile(t, temp, "key", "xxx", "")
+
+	// ACT
+	err := generateKeys(privateKeyFile, filepath.Join(temp, "key.pub"))
+
+	// ASSERT
+	require.Error(t, err)
+
+	// 既存のファイルは変更されない
+	contents, err := os.ReadFile(privateKeyFile)
+	require.NoError(t, err)
+	assert.Equal(t, "xxx", string(contents))
+}
+
+func TestLoadPublicKey_privateKey(t *testing.T) {
+
+	// ARRANGE
+	temp := t.TempDir()
+	privateKeyFile := filepath.Join(temp, "key")
+	require.NoError(t, generateKeys(privateKeyFile, filepath.Join(temp, "key.pub")))
+
+	// ACT
+	_, err := loadPublicKey(privateKeyFile)
+
+	// ASSERT
+	require.EqualError(t, err, "PUBLIC KEY not found: "+privateKeyFile)
+}
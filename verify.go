@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// verifier 一覧を出力する代わりに、マニフェストの各行と突き合わせる
+// 作成時と同じオプション、同じ鍵(--key-file)で一覧を作り直して比較するので、HMACの列も検証できる
+type verifier struct {
+	terminator byte
+	// マニフェストの行と、まだ一覧に現れていない数
+	lines    [][]byte
+	expected map[string]int
+	// マニフェストに無い一覧の行
+	added   [][]byte
+	pending []byte
+}
+
+func newVerifier(manifest []byte, nullTerminated bool) *verifier {
+
+	terminator := byte('\n')
+	if nullTerminated {
+		terminator = 0
+	}
+
+	// 署名付きのマニフェストは、署名行を除いて比較する
+	content, _, _ := splitSignature(manifest)
+
+	v := &verifier{
+		terminator: terminator,
+		expected:   map[string]int{},
+	}
+
+	lines := bytes.Split(content, []byte{terminator})
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		v.lines = append(v.lines, line)
+		v.expected[string(line)]++
+	}
+
+	return v
+}
+
+func (v *verifier) Write(p []byte) (int, error) {
+
+	v.pending = append(v.pending, p...)
+
+	for {
+		index := bytes.IndexByte(v.pending, v.terminator)
+		if index == -1 {
+			break
+		}
+
+		line := v.pending[:index]
+		if v.expected[string(line)] > 0 {
+			v.expected[string(line)]--
+		} else {
+			v.added = append(v.added, bytes.Clone(line))
+		}
+		v.pending = v.pending[index+1:]
+	}
+
+	return len(p), nil
+}
+
+// report 一致しなかった行を、マニフェストにのみある行は "- "、一覧にのみある行は "+ " を付けて出力する
+func (v *verifier) report(out io.Writer) error {
+
+	differences := 0
+
+	for _, line := range v.lines {
+		if v.expected[string(line)] > 0 {
+			v.expected[string(line)]--
+			fmt.Fprintf(out, "- %s\n", line)
+			differences++
+		}
+	}
+
+	for _, line := range v.added {
+		fmt.Fprintf(out, "+ %s\n", line)
+		differences++
+	}
+
+	if differences != 0 {
+		return fmt.Errorf("manifest does not match (%d differences)", differences)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifier(t *testing.T) {
+
+	// ARRANGE
+	verifier := newVerifier([]byte("a.txt\t1\nb.txt\t2\nb.txt\t2\n"), false)
+
+	// ACT
+	fmt.Fprint(verifier, "b.txt\t2\n")
+	fmt.Fprint(verifier, "a.txt")
+	fmt.Fprint(verifier, "\t1\nb.txt\t2\n")
+
+	out := new(bytes.Buffer)
+	err := verifier.report(out)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "", out.String())
+}
+
+func TestVerifier_differences(t *testing.T) {
+
+	// ARRANGE
+	verifier := newVerifier([]byte("a.txt\t1\x00b.txt\t2\x00c.txt\t3\x00"), true)
+
+	// ACT
+	fmt.Fprint(verifier, "a.txt\t1\x00")
+	fmt.Fprint(verifier, "b.txt\t9\x00")
+	fmt.Fprint(verifier, "d.txt\t4\x00")
+
+	out := new(bytes.Buffer)
+	err := verifier.report(out)
+
+	// ASSERT
+	assert.EqualError(t, err, "manifest does not match (4 differences)")
+	assert.Equal(t, "- b.txt\t2\n- c.txt\t3\n+ b.txt\t9\n+ d.txt\t4\n", out.String())
+}
+
+func TestVerifier_signed(t *testing.T) {
+
+	// ARRANGE
+	verifier := newVerifier([]byte("a.txt\t1\n"+signaturePrefix+"AAAA\n"), false)
+
+	// ACT
+	fmt.Fprint(verifier, "a.txt\t1\n")
+
+	out := new(bytes.Buffer)
+	err := verifier.report(out)
+
+	// ASSERT
+	require.NoError(t, err)
+	assert.Equal(t, "", out.String())
+}